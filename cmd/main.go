@@ -5,13 +5,20 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/yoanesber/go-idempotency-with-redis/config/cache"
 	"github.com/yoanesber/go-idempotency-with-redis/config/database"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/publisher"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/repository"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/service"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/store"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/worker"
 	"github.com/yoanesber/go-idempotency-with-redis/pkg/diagnostics"
 	"github.com/yoanesber/go-idempotency-with-redis/pkg/logger"
 	validation "github.com/yoanesber/go-idempotency-with-redis/pkg/util/validation-util"
@@ -30,7 +37,7 @@ func init() {
 
 func main() {
 	// Create base context with cancel for graceful shutdown
-	_, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	// Get environment variables
@@ -59,8 +66,14 @@ func main() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	// Create the idempotency store shared by the middleware and the janitor
+	idemStore := store.NewIdempotencyStore()
+
+	// Create the health service that backs the liveness and readiness probes
+	healthService := service.NewHealthService(service.DefaultHealthChecks()...)
+
 	// Setup router
-	r := routes.SetupRouter()
+	r := routes.SetupRouter(idemStore, healthService)
 	r.SetTrustedProxies(nil) // Set trusted proxies to nil to avoid issues with forwarded headers
 
 	// Log memory stats before initialization
@@ -72,8 +85,16 @@ func main() {
 	// Log memory stats after initialization
 	diagnostics.LogMemoryStats("After initialization")
 
+	// Start the background worker that purges expired idempotency keys
+	janitor := worker.NewIdempotencyJanitor(idemStore)
+	janitor.Start(ctx)
+
+	// Start the background worker that publishes the events written to the outbox
+	relay := worker.NewOutboxRelay(repository.NewOutboxMessageRepository(), publisher.NewPublisher())
+	relay.Start(ctx)
+
 	// Graceful shutdown
-	gracefulShutdown(cancel)
+	gracefulShutdown(cancel, janitor, relay, healthService)
 
 	// Start the server
 	var err error
@@ -124,7 +145,7 @@ func initializeDependencies() {
 	}
 }
 
-func gracefulShutdown(cancel context.CancelFunc) {
+func gracefulShutdown(cancel context.CancelFunc, janitor *worker.IdempotencyJanitor, relay *worker.OutboxRelay, healthService service.HealthService) {
 	// Handle graceful shutdown signals
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -133,9 +154,22 @@ func gracefulShutdown(cancel context.CancelFunc) {
 		sig := <-quit
 		logger.Info(fmt.Sprintf("Received signal: %s. Initiating graceful shutdown...", sig), nil)
 
+		// Report the service as not ready, and give the orchestrator time to stop sending traffic
+		healthService.MarkShuttingDown()
+		if drain, err := strconv.Atoi(os.Getenv("SHUTDOWN_DRAIN_SECONDS")); err == nil && drain > 0 {
+			logger.Info(fmt.Sprintf("Draining traffic for %d seconds...", drain), nil)
+			time.Sleep(time.Duration(drain) * time.Second)
+		}
+
 		// Cancel context
 		cancel()
 
+		// Wait for background workers to stop before closing their connections
+		logger.Info("Stopping idempotency janitor...", nil)
+		janitor.Wait()
+		logger.Info("Stopping outbox relay...", nil)
+		relay.Wait()
+
 		// Clean up resources
 		if redisInitialized {
 			logger.Info("Closing Redis connection...", nil)
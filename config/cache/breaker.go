@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	breakerutil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/breaker-util"
+	envutil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/env-util"
+)
+
+const (
+	defaultBreakerFailureThreshold = 5  // Default number of consecutive failures that open the circuit, used when REDIS_BREAKER_FAILURE_THRESHOLD is not set
+	defaultBreakerOpenSeconds      = 30 // Default time the circuit stays open, used when REDIS_BREAKER_OPEN_SECONDS is not set
+)
+
+// RedisBreaker is the circuit breaker around every command sent by the Redis client
+var RedisBreaker *breakerutil.CircuitBreaker
+
+// NewRedisBreaker creates the circuit breaker of the Redis client from the REDIS_BREAKER_FAILURE_THRESHOLD
+// and REDIS_BREAKER_OPEN_SECONDS environment variables
+func NewRedisBreaker() *breakerutil.CircuitBreaker {
+	threshold := envutil.GetPositiveInt("REDIS_BREAKER_FAILURE_THRESHOLD", defaultBreakerFailureThreshold)
+	openSeconds := envutil.GetPositiveInt("REDIS_BREAKER_OPEN_SECONDS", defaultBreakerOpenSeconds)
+
+	return breakerutil.NewCircuitBreaker("redis", threshold, time.Duration(openSeconds)*time.Second)
+}
+
+// GetRedisCircuitState returns the state of the Redis circuit breaker: CLOSED, OPEN or HALF_OPEN
+// It returns an empty string if the Redis client has not been initialized
+func GetRedisCircuitState() string {
+	if RedisBreaker == nil {
+		return ""
+	}
+
+	return RedisBreaker.State()
+}
+
+// breakerHook is a Redis hook that sends every command, and every pipeline, through the circuit breaker
+// While the circuit is open, commands fail immediately with breakerutil.ErrOpen instead of waiting on a dead instance
+type breakerHook struct {
+	breaker *breakerutil.CircuitBreaker
+}
+
+// NewBreakerHook creates a Redis hook that guards the client with the given circuit breaker
+func NewBreakerHook(breaker *breakerutil.CircuitBreaker) redis.Hook {
+	return &breakerHook{breaker: breaker}
+}
+
+// BeforeProcess rejects the command while the circuit is open
+func (h *breakerHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return ctx, h.breaker.Allow()
+}
+
+// AfterProcess records the outcome of the command, unless it was rejected by the circuit breaker
+// A command cancelled by its caller is neither a success nor a failure, so it only releases its call
+func (h *breakerHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	switch err := cmd.Err(); {
+	case errors.Is(err, breakerutil.ErrOpen):
+	case errors.Is(err, context.Canceled):
+		h.breaker.Release()
+	default:
+		h.breaker.Record(isConnectionFailure(err))
+	}
+
+	return nil
+}
+
+// BeforeProcessPipeline rejects the pipeline while the circuit is open
+func (h *breakerHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return ctx, h.breaker.Allow()
+}
+
+// AfterProcessPipeline records the outcome of the pipeline, which failed if any of its commands failed to reach Redis
+// A pipeline cancelled by its caller, without any such failure, only releases its call
+func (h *breakerHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	failed, cancelled := false, false
+	for _, cmd := range cmds {
+		switch err := cmd.Err(); {
+		case errors.Is(err, breakerutil.ErrOpen):
+			return nil
+		case errors.Is(err, context.Canceled):
+			cancelled = true
+		case isConnectionFailure(err):
+			failed = true
+		}
+	}
+
+	if cancelled && !failed {
+		h.breaker.Release()
+		return nil
+	}
+
+	h.breaker.Record(failed)
+	return nil
+}
+
+// isConnectionFailure reports whether the error means Redis could not be reached or did not respond in time
+// A missing key and an error reply from the server are not failures of the instance
+func isConnectionFailure(err error) bool {
+	if err == nil || errors.Is(err, redis.Nil) {
+		return false
+	}
+
+	var replyErr redis.Error
+	return !errors.As(err, &replyErr)
+}
@@ -5,43 +5,139 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/yoanesber/go-idempotency-with-redis/pkg/logger"
+	envutil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/env-util"
 
 	"github.com/go-redis/redis/v8" // Redis client for Go
 )
 
+const (
+	RedisModeStandalone = "STANDALONE" // A single Redis node, addressed by REDIS_HOST and REDIS_PORT
+	RedisModeSentinel   = "SENTINEL"   // A Sentinel-managed primary, discovered through the sentinels in REDIS_ADDRS
+	RedisModeCluster    = "CLUSTER"    // A Redis Cluster, discovered through the seed nodes in REDIS_ADDRS
+)
+
 var (
-	once        sync.Once
-	RedisClient *redis.Client
-	RedisDB     string
-	RedisHost   string
-	RedisPort   string
-	RedisUser   string
-	RedisPass   string
-	IsFlushDB   string
+	once              sync.Once
+	RedisClient       redis.UniversalClient
+	RedisMode         string
+	RedisDB           string
+	RedisHost         string
+	RedisPort         string
+	RedisAddrs        string
+	RedisMasterName   string
+	RedisUser         string
+	RedisPass         string
+	RedisSentinelPass string
+	IsFlushDB         string
 )
 
 // LoadRedisEnv loads Redis configuration from environment variables
+// Standalone mode requires REDIS_HOST and REDIS_PORT; Sentinel and Cluster modes require REDIS_ADDRS,
+// and Sentinel mode also requires REDIS_MASTER_NAME
 func LoadRedisEnv() bool {
+	RedisMode = strings.ToUpper(os.Getenv("REDIS_MODE"))
 	RedisDB = os.Getenv("REDIS_DB")
 	RedisHost = os.Getenv("REDIS_HOST")
 	RedisPort = os.Getenv("REDIS_PORT")
+	RedisAddrs = os.Getenv("REDIS_ADDRS")
+	RedisMasterName = os.Getenv("REDIS_MASTER_NAME")
 	RedisUser = os.Getenv("REDIS_USER")
 	RedisPass = os.Getenv("REDIS_PASS")
+	RedisSentinelPass = os.Getenv("REDIS_SENTINEL_PASS")
 	IsFlushDB = os.Getenv("REDIS_FLUSH_DB")
 
-	if RedisDB == "" || RedisHost == "" || RedisPort == "" {
-		logger.Panic("One or more required environment variables for Redis are not set", nil)
+	if RedisMode == "" {
+		RedisMode = RedisModeStandalone
+	}
+
+	switch RedisMode {
+	case RedisModeStandalone:
+		if RedisDB == "" || RedisHost == "" || RedisPort == "" {
+			logger.Panic("One or more required environment variables for Redis are not set", nil)
+			return false
+		}
+	case RedisModeSentinel:
+		if RedisAddrs == "" || RedisMasterName == "" {
+			logger.Panic("REDIS_ADDRS and REDIS_MASTER_NAME must be set in Redis Sentinel mode", nil)
+			return false
+		}
+	case RedisModeCluster:
+		if RedisAddrs == "" {
+			logger.Panic("REDIS_ADDRS must be set in Redis Cluster mode", nil)
+			return false
+		}
+	default:
+		logger.Panic(fmt.Sprintf("Unsupported Redis mode: %s", RedisMode), nil)
 		return false
 	}
 
 	return true
 }
 
+// NewRedisOptions builds the options of the Redis client from the loaded configuration
+// The pool size and timeouts are read from REDIS_POOL_SIZE and REDIS_*_TIMEOUT_MS; unset values keep the go-redis defaults
+func NewRedisOptions() (*redis.UniversalOptions, error) {
+	redisDb := 0
+	if RedisDB != "" {
+		db, err := strconv.Atoi(RedisDB)
+		if err != nil {
+			return nil, fmt.Errorf("invalid REDIS_DB: %w", err)
+		}
+		redisDb = db
+	}
+
+	// Redis Cluster only supports database 0
+	if RedisMode == RedisModeCluster && redisDb != 0 {
+		return nil, fmt.Errorf("redis cluster only supports REDIS_DB=0")
+	}
+
+	addrs := []string{fmt.Sprintf("%s:%s", RedisHost, RedisPort)}
+	if RedisMode != RedisModeStandalone {
+		addrs = splitAddrs(RedisAddrs)
+	}
+
+	tlsConfig, err := LoadRedisTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return &redis.UniversalOptions{
+		Addrs:            addrs,
+		DB:               redisDb,
+		Username:         RedisUser,
+		Password:         RedisPass,
+		MasterName:       RedisMasterName,
+		SentinelPassword: RedisSentinelPass,
+		TLSConfig:        tlsConfig,
+		PoolSize:         envutil.GetNonNegativeInt("REDIS_POOL_SIZE", 0),
+		DialTimeout:      getEnvMillis("REDIS_DIAL_TIMEOUT_MS"),
+		ReadTimeout:      getEnvMillis("REDIS_READ_TIMEOUT_MS"),
+		WriteTimeout:     getEnvMillis("REDIS_WRITE_TIMEOUT_MS"),
+		PoolTimeout:      getEnvMillis("REDIS_POOL_TIMEOUT_MS"),
+		IdleTimeout:      getEnvMillis("REDIS_IDLE_TIMEOUT_MS"),
+	}, nil
+}
+
+// NewRedisClient creates the Redis client of the loaded mode with the given options
+// The client is created explicitly per mode, so a single seed node still yields a cluster client in Cluster mode
+func NewRedisClient(opts *redis.UniversalOptions) redis.UniversalClient {
+	switch RedisMode {
+	case RedisModeSentinel:
+		return redis.NewFailoverClient(opts.Failover())
+	case RedisModeCluster:
+		return redis.NewClusterClient(opts.Cluster())
+	default:
+		return redis.NewClient(opts.Simple())
+	}
+}
+
 // InitRedis initializes the Redis client using environment variables
-// It constructs the connection string and calls ConnectRedis to establish the connection
+// It builds the client options for the configured mode and checks the connection
 func InitRedis() bool {
 	isSuccess := true
 	once.Do(func() {
@@ -50,28 +146,22 @@ func InitRedis() bool {
 			return
 		}
 
-		logger.Info("Connecting to Redis...", nil)
+		logger.Info(fmt.Sprintf("Connecting to Redis (%s mode)...", RedisMode), nil)
 
 		// Initialize the Redis client
-		redisDb, _ := strconv.Atoi(RedisDB)
-		RedisClient = redis.NewClient(&redis.Options{
-			Addr:     fmt.Sprintf("%s:%s", RedisHost, RedisPort),
-			Username: RedisUser,
-			Password: RedisPass,
-			DB:       redisDb,
-			// DialTimeout:        10 * time.Second,
-			// ReadTimeout:        30 * time.Second,
-			// WriteTimeout:       30 * time.Second,
-			// PoolSize:           10,
-			// PoolTimeout:        30 * time.Second,
-			// IdleTimeout:        500 * time.Millisecond,
-			// IdleCheckFrequency: 500 * time.Millisecond,
-			// TLSConfig: &tls.Config{
-			// 	InsecureSkipVerify: true,
-			// },
-		})
+		opts, err := NewRedisOptions()
+		if err != nil {
+			logger.Fatal(fmt.Sprintf("Invalid Redis configuration: %v", err), nil)
+			isSuccess = false
+			return
+		}
+		RedisClient = NewRedisClient(opts)
+
+		// Guard every command with the circuit breaker, so a dead instance is not hammered
+		RedisBreaker = NewRedisBreaker()
+		RedisClient.AddHook(NewBreakerHook(RedisBreaker))
 
-		_, err := RedisClient.Ping(context.Background()).Result()
+		_, err = RedisClient.Ping(context.Background()).Result()
 		if err != nil {
 			logger.Fatal(fmt.Sprintf("Failed to connect to Redis: %v", err), nil)
 			isSuccess = false
@@ -84,12 +174,12 @@ func InitRedis() bool {
 		// This is typically used for testing or development purposes
 		if IsFlushDB == "TRUE" {
 			logger.Info("Flushing Redis database...", nil)
-			if status, err := RedisClient.FlushDBAsync(context.Background()).Result(); err != nil {
+			if err := flushDB(context.Background(), RedisClient); err != nil {
 				logger.Error(fmt.Sprintf("Failed to flush Redis database: %v", err), nil)
 				isSuccess = false
 				return
 			} else {
-				logger.Info(fmt.Sprintf("Redis database flushed successfully: %s", status), nil)
+				logger.Info("Redis database flushed successfully", nil)
 			}
 		}
 	})
@@ -99,7 +189,7 @@ func InitRedis() bool {
 
 // GetRedisClient retrieves the Redis client instance
 // If the client is not initialized, it calls InitRedis to set it up
-func GetRedisClient() *redis.Client {
+func GetRedisClient() redis.UniversalClient {
 	if RedisClient == nil {
 		if !InitRedis() {
 			logger.Error("Failed to initialize Redis client", nil)
@@ -110,6 +200,16 @@ func GetRedisClient() *redis.Client {
 	return RedisClient
 }
 
+// PingRedis checks that Redis is reachable, without initializing the client if it has not been initialized yet
+// The ping goes through the circuit breaker, so it fails fast while the circuit is open
+func PingRedis(ctx context.Context) error {
+	if RedisClient == nil {
+		return fmt.Errorf("redis client is not initialized")
+	}
+
+	return RedisClient.Ping(ctx).Err()
+}
+
 // CloseRedis closes the Redis client connection
 func CloseRedis() {
 	if RedisClient != nil {
@@ -126,3 +226,33 @@ func CloseRedis() {
 	RedisClient = nil  // Clear the RedisClient variable to prevent further use
 	logger.Warn("Redis client is nil, nothing to close", nil)
 }
+
+// flushDB flushes the Redis database asynchronously
+// In Cluster mode, every primary is flushed, as each one holds a part of the keyspace
+func flushDB(ctx context.Context, client redis.UniversalClient) error {
+	if cluster, ok := client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			return node.FlushDBAsync(ctx).Err()
+		})
+	}
+
+	return client.FlushDBAsync(ctx).Err()
+}
+
+// splitAddrs splits a comma-separated list of host:port addresses, ignoring empty entries
+func splitAddrs(value string) []string {
+	var addrs []string
+	for _, addr := range strings.Split(value, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+
+	return addrs
+}
+
+// getEnvMillis reads a duration in milliseconds from the environment variable with the given name
+// It returns 0, i.e. the go-redis default, if the variable is not set or is invalid
+func getEnvMillis(name string) time.Duration {
+	return time.Duration(envutil.GetNonNegativeInt(name, 0)) * time.Millisecond
+}
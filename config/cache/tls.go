@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// LoadRedisTLSConfig builds the TLS configuration of the Redis client from environment variables
+// It returns nil if REDIS_TLS_ENABLED is not "TRUE". The server certificate is verified against the CA in
+// REDIS_TLS_CA_FILE (or the system roots), and REDIS_TLS_CERT_FILE and REDIS_TLS_KEY_FILE enable mutual TLS
+func LoadRedisTLSConfig() (*tls.Config, error) {
+	if os.Getenv("REDIS_TLS_ENABLED") != "TRUE" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         os.Getenv("REDIS_TLS_SERVER_NAME"),
+		InsecureSkipVerify: os.Getenv("REDIS_TLS_INSECURE_SKIP_VERIFY") == "TRUE",
+	}
+
+	// Trust the private CA that signed the Redis server certificate
+	if caFile := os.Getenv("REDIS_TLS_CA_FILE"); caFile != "" {
+		caPEM, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Redis CA file: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no valid certificates found in Redis CA file %s", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	// Present a client certificate if the server requires mutual TLS
+	certFile := os.Getenv("REDIS_TLS_CERT_FILE")
+	keyFile := os.Getenv("REDIS_TLS_KEY_FILE")
+	if (certFile == "") != (keyFile == "") {
+		return nil, fmt.Errorf("REDIS_TLS_CERT_FILE and REDIS_TLS_KEY_FILE must be set together")
+	}
+
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load Redis client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"sync"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/yoanesber/go-idempotency-with-redis/internal/entity"
 	"github.com/yoanesber/go-idempotency-with-redis/pkg/logger"
+	keyutil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/key-util"
 )
 
 var (
@@ -147,7 +149,10 @@ func MigratePostgres() error {
 
 		// Drop and recreate tables if they exist
 		err := tx.Migrator().DropTable(
+			&entity.OutboxMessage{},
+			&entity.TransactionEvent{},
 			&entity.Transaction{},
+			&entity.IdempotencyLock{},
 			&entity.IdempotencyCache{},
 			&entity.Consumer{})
 		if err != nil {
@@ -158,11 +163,19 @@ func MigratePostgres() error {
 		err = tx.AutoMigrate(
 			&entity.Consumer{},
 			&entity.IdempotencyCache{},
-			&entity.Transaction{})
+			&entity.IdempotencyLock{},
+			&entity.Transaction{},
+			&entity.TransactionEvent{},
+			&entity.OutboxMessage{})
 		if err != nil {
 			return fmt.Errorf("failed to migrate database: %v", err)
 		}
 
+		// Change the type of the idempotency key columns to follow the key format
+		if err := migrateIdempotencyKeyColumns(tx); err != nil {
+			return err
+		}
+
 		if DBSeed == "TRUE" {
 			// Import initial data from the seed file
 			if DBSeedFile == "" {
@@ -193,6 +206,32 @@ func MigratePostgres() error {
 	return nil
 }
 
+// migrateIdempotencyKeyColumns changes the type of the idempotency key columns to the column type of the key policy.
+// The columns are created as uuid, which only fits UUID keys; ULID and opaque keys need a character type instead.
+func migrateIdempotencyKeyColumns(tx *gorm.DB) error {
+	columnType := keyutil.NewKeyPolicy().ColumnType()
+	if columnType == "uuid" {
+		return nil
+	}
+
+	columns := map[string]string{
+		entity.IdempotencyCache{}.TableName(): "key",
+		entity.IdempotencyLock{}.TableName():  "key",
+		entity.Transaction{}.TableName():      "idempotency_cache_key",
+	}
+
+	for table, column := range columns {
+		stmt := fmt.Sprintf(`ALTER TABLE %s ALTER COLUMN "%s" TYPE %s USING "%s"::text`, table, column, columnType, column)
+		if err := tx.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("failed to change type of %s.%s to %s: %v", table, column, columnType, err)
+		}
+	}
+
+	logger.Info(fmt.Sprintf("Idempotency key columns changed to %s", columnType), nil)
+
+	return nil
+}
+
 // GetPostgres returns the GORM database instance
 func GetPostgres() *gorm.DB {
 	if db == nil {
@@ -204,6 +243,25 @@ func GetPostgres() *gorm.DB {
 	return db
 }
 
+// SetPostgres replaces the GORM database connection, e.g. with a connection to a mocked database in tests.
+func SetPostgres(conn *gorm.DB) {
+	db = conn
+}
+
+// PingPostgres checks that the database is reachable, without initializing the connection if it has not been initialized yet
+func PingPostgres(ctx context.Context) error {
+	if db == nil {
+		return fmt.Errorf("database connection is not initialized")
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	return sqlDB.PingContext(ctx)
+}
+
 // ClosePostgres closes the database connection (optional, for when needed)
 func ClosePostgres() {
 	sqlDB, err := db.DB()
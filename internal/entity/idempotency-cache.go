@@ -1,18 +1,38 @@
 package entity
 
 import (
+	"maps"
 	"time"
 )
 
 // IdempotencyCache represents an idempotency key entity.
 // It is used to ensure that a request is processed only once, even if it is sent multiple times.
+// Keys are namespaced by a scope (e.g., the client or consumer), so keys chosen by different clients never collide.
+// The key column is created as uuid and changed during migration to follow the key format of IDEMPOTENCY_KEY_FORMAT.
 type IdempotencyCache struct {
-	Key             string    `gorm:"type:uuid;primaryKey" json:"key" validate:"required,uuid4"`
-	BodyHash        string    `gorm:"type:text;not null" json:"bodyHash" validate:"required"`
-	ResponsePayload string    `gorm:"type:text;not null" json:"responsePayload" validate:"required"`
-	CreatedAt       time.Time `gorm:"type:timestamptz;autoCreateTime;default:now()" json:"createdAt,omitempty"`
-	UpdatedAt       time.Time `gorm:"type:timestamptz;autoUpdateTime;default:now()" json:"updatedAt,omitempty"`
-	ExpiredAt       time.Time `gorm:"type:timestamptz;not null" json:"expiredAt" validate:"required"`
+	Scope           string            `gorm:"type:varchar(255);primaryKey" json:"scope" validate:"required,max=255"`
+	Key             string            `gorm:"type:uuid;primaryKey" json:"key" validate:"required,max=255"`
+	RequestMethod   string            `gorm:"type:varchar(10);not null;default:''" json:"requestMethod"`
+	RequestPath     string            `gorm:"type:text;not null;default:''" json:"requestPath"`
+	QueryHash       string            `gorm:"type:text;not null;default:''" json:"queryHash"`
+	HeadersHash     string            `gorm:"type:text;not null;default:''" json:"headersHash"`
+	BodyHash        string            `gorm:"type:text;not null" json:"bodyHash"`
+	StatusCode      int               `gorm:"type:integer;not null;default:0" json:"statusCode"`
+	ResponseHeaders map[string]string `gorm:"type:jsonb;serializer:json" json:"responseHeaders,omitempty"`
+	ResponsePayload string            `gorm:"type:text;not null" json:"responsePayload" validate:"required"`
+	CreatedAt       time.Time         `gorm:"type:timestamptz;autoCreateTime;default:now()" json:"createdAt,omitempty"`
+	UpdatedAt       time.Time         `gorm:"type:timestamptz;autoUpdateTime;default:now()" json:"updatedAt,omitempty"`
+	ExpiredAt       time.Time         `gorm:"type:timestamptz;not null" json:"expiredAt" validate:"required"`
+}
+
+// IdempotencyCacheFilter holds the optional filters used to list idempotency keys.
+// Zero values mean the filter is not applied.
+type IdempotencyCacheFilter struct {
+	Scope       string     // Only keys in this scope
+	CreatedFrom *time.Time // Only keys created at or after this time
+	CreatedTo   *time.Time // Only keys created at or before this time
+	Expired     *bool      // Only expired keys if true, only unexpired keys if false
+	StatusCode  int        // Only keys whose stored response has this status code
 }
 
 // TableName overrides the table name used by GORM to `idempotency_keys` and `idempotency_logs`.
@@ -20,6 +40,17 @@ func (IdempotencyCache) TableName() string {
 	return "idempotency_cache"
 }
 
+// IsCompleted reports whether the response of the original request has been stored.
+// An entry without a status code was created while the request was still being processed.
+func (ik *IdempotencyCache) IsCompleted() bool {
+	return ik != nil && ik.StatusCode != 0
+}
+
+// IsExpired reports whether the idempotency key has passed its expiration time.
+func (ik *IdempotencyCache) IsExpired() bool {
+	return ik != nil && !ik.ExpiredAt.After(time.Now())
+}
+
 // Equals compares two IdempotencyCache objects for equality.
 func (ik *IdempotencyCache) Equals(other *IdempotencyCache) bool {
 	if ik == nil && other == nil {
@@ -30,8 +61,15 @@ func (ik *IdempotencyCache) Equals(other *IdempotencyCache) bool {
 		return false
 	}
 
-	if (ik.Key != other.Key) ||
+	if (ik.Scope != other.Scope) ||
+		(ik.Key != other.Key) ||
+		(ik.RequestMethod != other.RequestMethod) ||
+		(ik.RequestPath != other.RequestPath) ||
+		(ik.QueryHash != other.QueryHash) ||
+		(ik.HeadersHash != other.HeadersHash) ||
 		(ik.BodyHash != other.BodyHash) ||
+		(ik.StatusCode != other.StatusCode) ||
+		(!maps.Equal(ik.ResponseHeaders, other.ResponseHeaders)) ||
 		(ik.ResponsePayload != other.ResponsePayload) ||
 		(ik.CreatedAt != other.CreatedAt) ||
 		(ik.UpdatedAt != other.UpdatedAt) ||
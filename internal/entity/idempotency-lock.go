@@ -0,0 +1,26 @@
+package entity
+
+import (
+	"time"
+)
+
+// IdempotencyLock represents the in-flight reservation of an idempotency key.
+// It is used by the Postgres idempotency store, so concurrent requests with the same key are not processed at the same time.
+// The reservation is held by the request that owns the token, until it is released or its lease expires.
+type IdempotencyLock struct {
+	Scope     string    `gorm:"type:varchar(255);primaryKey" json:"scope"`
+	Key       string    `gorm:"type:uuid;primaryKey" json:"key"`
+	Token     string    `gorm:"type:varchar(64);not null" json:"-"`
+	CreatedAt time.Time `gorm:"type:timestamptz;autoCreateTime;default:now()" json:"createdAt,omitempty"`
+	ExpiredAt time.Time `gorm:"type:timestamptz;not null" json:"expiredAt"`
+}
+
+// TableName overrides the table name used by GORM to `idempotency_lock`.
+func (IdempotencyLock) TableName() string {
+	return "idempotency_lock"
+}
+
+// IsExpired reports whether the lease of the reservation has expired.
+func (il *IdempotencyLock) IsExpired() bool {
+	return il != nil && !il.ExpiredAt.After(time.Now())
+}
@@ -0,0 +1,34 @@
+package entity
+
+import (
+	"time"
+)
+
+const (
+	OutboxStatusPending   = "pending"   // Waiting to be delivered, or to be retried after a failed delivery
+	OutboxStatusDelivered = "delivered" // Delivered to the publisher
+	OutboxStatusFailed    = "failed"    // Given up after the maximum number of delivery attempts
+)
+
+// OutboxMessage represents an event waiting to be published, in the transactional outbox.
+// It is written in the same database transaction as the change it describes, so an event is never lost or published for a rolled back change.
+// The outbox relay delivers pending messages at least once; consumers dedupe them by their idempotency key.
+type OutboxMessage struct {
+	ID                    string     `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	Topic                 string     `gorm:"type:varchar(100);not null" json:"topic"`
+	AggregateID           string     `gorm:"type:uuid;not null" json:"aggregateId"` // ID of the entity the event is about, e.g. the transaction
+	IdempotencyCacheScope string     `gorm:"type:varchar(255)" json:"idempotencyCacheScope,omitempty"`
+	IdempotencyCacheKey   string     `gorm:"type:varchar(255)" json:"idempotencyCacheKey,omitempty"`
+	Payload               string     `gorm:"type:jsonb;not null" json:"payload"`
+	Status                string     `gorm:"type:varchar(20);not null;default:pending;check:status IN ('pending','delivered','failed');index:idx_outbox_pending,priority:1" json:"status"`
+	Attempts              int        `gorm:"not null;default:0" json:"attempts"`
+	LastError             string     `gorm:"type:text" json:"lastError,omitempty"`
+	NextAttemptAt         time.Time  `gorm:"type:timestamptz;not null;default:now();index:idx_outbox_pending,priority:2" json:"nextAttemptAt"`
+	CreatedAt             time.Time  `gorm:"type:timestamptz;autoCreateTime;default:now()" json:"createdAt"`
+	DeliveredAt           *time.Time `gorm:"type:timestamptz" json:"deliveredAt,omitempty"`
+}
+
+// TableName overrides the table name used by GORM to `outbox`.
+func (OutboxMessage) TableName() string {
+	return "outbox"
+}
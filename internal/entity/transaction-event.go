@@ -0,0 +1,51 @@
+package entity
+
+import (
+	"time"
+
+	"gopkg.in/go-playground/validator.v9"
+
+	validation "github.com/yoanesber/go-idempotency-with-redis/pkg/util/validation-util"
+)
+
+const (
+	TransactionEventActorSystem = "system" // Actor recorded when a status change does not name one
+)
+
+// TransactionEvent represents a status change of a transaction in the database.
+// Every status change, including the creation of the transaction, is recorded, so the history of a payment can be reconstructed.
+type TransactionEvent struct {
+	ID                    string       `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	TransactionID         string       `gorm:"type:uuid;not null;index:idx_transaction_events_transaction" json:"transactionId"`
+	FromStatus            string       `gorm:"type:varchar(20)" json:"fromStatus"` // Empty for the creation of the transaction
+	ToStatus              string       `gorm:"type:varchar(20);not null" json:"toStatus"`
+	Actor                 string       `gorm:"type:varchar(100);not null" json:"actor"`
+	Reason                string       `gorm:"type:varchar(500)" json:"reason,omitempty"`
+	IdempotencyCacheScope string       `gorm:"type:varchar(255)" json:"idempotencyCacheScope,omitempty"`
+	IdempotencyCacheKey   string       `gorm:"type:varchar(255)" json:"idempotencyCacheKey,omitempty"`
+	CreatedAt             time.Time    `gorm:"type:timestamptz;autoCreateTime;default:now();index:idx_transaction_events_transaction" json:"createdAt"`
+	Transaction           *Transaction `gorm:"foreignKey:TransactionID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+// Override the TableName method to specify the table name
+// in the database. This is optional if you want to use the default naming convention.
+func (TransactionEvent) TableName() string {
+	return "transaction_events"
+}
+
+// TransactionStatusChange is the request body of a transaction status transition.
+// Both fields are optional; the actor defaults to "system".
+type TransactionStatusChange struct {
+	Actor  string `json:"actor" validate:"max=100"`
+	Reason string `json:"reason" validate:"max=500"`
+}
+
+// Validate validates the TransactionStatusChange struct using the validator package.
+func (c *TransactionStatusChange) Validate() error {
+	var v *validator.Validate = validation.GetValidator()
+
+	if err := v.Struct(c); err != nil {
+		return err
+	}
+	return nil
+}
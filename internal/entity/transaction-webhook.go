@@ -0,0 +1,26 @@
+package entity
+
+import (
+	"gopkg.in/go-playground/validator.v9"
+
+	validation "github.com/yoanesber/go-idempotency-with-redis/pkg/util/validation-util"
+)
+
+// TransactionWebhook is the callback the transaction processor sends when the status of a submitted transaction changes.
+// The event ID identifies the callback, so a callback that is delivered more than once is applied only once.
+type TransactionWebhook struct {
+	EventID       string `json:"eventId" validate:"required,max=255"`
+	TransactionID string `json:"transactionId" validate:"required,uuid4"`
+	Status        string `json:"status" validate:"required,oneof=processing completed failed"`
+	Reason        string `json:"reason" validate:"max=500"`
+}
+
+// Validate validates the TransactionWebhook struct using the validator package.
+func (w *TransactionWebhook) Validate() error {
+	var v *validator.Validate = validation.GetValidator()
+
+	if err := v.Struct(w); err != nil {
+		return err
+	}
+	return nil
+}
@@ -9,20 +9,31 @@ import (
 )
 
 const (
-	TransactionStatusPending = "pending"
+	TransactionStatusPending    = "pending"
+	TransactionStatusProcessing = "processing"
+	TransactionStatusCompleted  = "completed"
+	TransactionStatusFailed     = "failed"
+)
+
+const (
+	TransactionTypePayment      = "payment"
+	TransactionTypeWithdrawal   = "withdrawal"
+	TransactionTypeDisbursement = "disbursement"
 )
 
 // Transaction represents the transaction entity in the database.
 type Transaction struct {
-	ID                  string     `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
-	IdempotencyCacheKey string     `gorm:"type:uuid;not null;unique" json:"idempotencyCacheKey" validate:"required"`
-	Type                string     `gorm:"type:varchar(20);not null;check:type IN ('payment','withdrawal','disbursement')" json:"type" validate:"required,max=20,oneof=payment withdrawal disbursement"`
-	Amount              float64    `gorm:"type:decimal(10,2);not null" json:"amount" validate:"required,numeric"`
-	Status              string     `gorm:"type:varchar(20);not null;check:status IN ('pending','processing','completed','failed')" json:"status"`
-	ConsumerID          string     `gorm:"type:uuid;not null" json:"consumerId" validate:"required,uuid4"`
-	Consumer            *Consumer  `gorm:"foreignKey:ConsumerID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"consumer,omitempty"`
-	CreatedAt           *time.Time `gorm:"type:timestamptz;autoCreateTime;default:now()" json:"createdAt,omitempty"`
-	UpdatedAt           *time.Time `gorm:"type:timestamptz;autoUpdateTime;default:now()" json:"updatedAt,omitempty"`
+	ID                    string     `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	IdempotencyCacheScope string     `gorm:"type:varchar(255);not null;uniqueIndex:idx_transactions_idempotency_cache" json:"idempotencyCacheScope" validate:"required,max=255"`
+	IdempotencyCacheKey   string     `gorm:"type:uuid;not null;uniqueIndex:idx_transactions_idempotency_cache" json:"idempotencyCacheKey" validate:"required"`
+	Type                  string     `gorm:"type:varchar(20);not null;check:type IN ('payment','withdrawal','disbursement')" json:"type" validate:"required,max=20,oneof=payment withdrawal disbursement"`
+	Amount                float64    `gorm:"type:decimal(10,2);not null" json:"amount" validate:"required,numeric"`
+	Status                string     `gorm:"type:varchar(20);not null;check:status IN ('pending','processing','completed','failed')" json:"status"`
+	Version               int        `gorm:"not null;default:1" json:"version"` // Incremented on every status change, for optimistic concurrency
+	ConsumerID            string     `gorm:"type:uuid;not null" json:"consumerId" validate:"required,uuid4"`
+	Consumer              *Consumer  `gorm:"foreignKey:ConsumerID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL" json:"consumer,omitempty"`
+	CreatedAt             *time.Time `gorm:"type:timestamptz;autoCreateTime;default:now()" json:"createdAt,omitempty"`
+	UpdatedAt             *time.Time `gorm:"type:timestamptz;autoUpdateTime;default:now()" json:"updatedAt,omitempty"`
 }
 
 // Override the TableName method to specify the table name
@@ -42,6 +53,7 @@ func (t *Transaction) Equals(other *Transaction) bool {
 	}
 
 	if (t.ID != other.ID) ||
+		(t.IdempotencyCacheScope != other.IdempotencyCacheScope) ||
 		(t.IdempotencyCacheKey != other.IdempotencyCacheKey) ||
 		(t.Type != other.Type) ||
 		(t.Amount != other.Amount) ||
@@ -1,11 +1,15 @@
 package handler
 
 import (
+	"errors"
+	"strconv"
+
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
 
 	"github.com/yoanesber/go-idempotency-with-redis/internal/service"
 	httputil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/http-util"
+	redisutil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/redis-util"
 )
 
 // This struct defines the DataRedisHandler which handles HTTP requests related to Redis data.
@@ -29,9 +33,11 @@ func NewDataRedisHandler(dataRedisService service.DataRedisService) *DataRedisHa
 // @Param        key   path      string  true  "Redis key"
 // @Success      200  {object}  HttpResponse for successful retrieval
 // @Failure      400  {object}  HttpResponse for bad request
+// @Failure      403  {object}  HttpResponse for key not allowed
 // @Failure      404  {object}  HttpResponse for not found
 // @Failure      500  {object}  HttpResponse for internal server error
-// @Router       /dataredis/string/{key} [get]
+// @Failure      504  {object}  HttpResponse for Redis timeout
+// @Router       /admin/dataredis/string/{key} [get]
 func (h *DataRedisHandler) GetStringValue(c *gin.Context) {
 	// Parse the key from the URL parameter
 	key := c.Param("key")
@@ -41,12 +47,22 @@ func (h *DataRedisHandler) GetStringValue(c *gin.Context) {
 	}
 
 	// Call the service to get the string value from Redis
-	value, err := h.Service.GetStringValue(key)
+	value, err := h.Service.GetStringValue(c.Request.Context(), key)
 	if err == redis.Nil {
 		httputil.NotFound(c, "Value not found", "Key does not exist in Redis")
 		return
 	}
 
+	if errors.Is(err, service.ErrRedisKeyNotAllowed) {
+		httputil.Forbidden(c, "Key not allowed", "Key does not start with an allow-listed prefix")
+		return
+	}
+
+	if redisutil.IsTimeout(err) {
+		httputil.GatewayTimeout(c, "Redis timed out", err.Error())
+		return
+	}
+
 	if err != nil {
 		httputil.InternalServerError(c, "Failed to get string value", err.Error())
 		return
@@ -71,9 +87,11 @@ func (h *DataRedisHandler) GetStringValue(c *gin.Context) {
 // @Param        key   path      string  true  "Redis key"
 // @Success      200  {object}  HttpResponse for successful retrieval
 // @Failure      400  {object}  HttpResponse for bad request
+// @Failure      403  {object}  HttpResponse for key not allowed
 // @Failure      404  {object}  HttpResponse for not found
 // @Failure      500  {object}  HttpResponse for internal server error
-// @Router       /dataredis/json/{key} [get]
+// @Failure      504  {object}  HttpResponse for Redis timeout
+// @Router       /admin/dataredis/json/{key} [get]
 func (h *DataRedisHandler) GetJSONValue(c *gin.Context) {
 	// Parse the key from the URL parameter
 	key := c.Param("key")
@@ -83,12 +101,22 @@ func (h *DataRedisHandler) GetJSONValue(c *gin.Context) {
 	}
 
 	// Call the service to get the JSON value from Redis
-	value, err := h.Service.GetJSONValue(key)
+	value, err := h.Service.GetJSONValue(c.Request.Context(), key)
 	if err == redis.Nil {
 		httputil.NotFound(c, "Value not found", "Key does not exist in Redis")
 		return
 	}
 
+	if errors.Is(err, service.ErrRedisKeyNotAllowed) {
+		httputil.Forbidden(c, "Key not allowed", "Key does not start with an allow-listed prefix")
+		return
+	}
+
+	if redisutil.IsTimeout(err) {
+		httputil.GatewayTimeout(c, "Redis timed out", err.Error())
+		return
+	}
+
 	if err != nil {
 		httputil.InternalServerError(c, "Failed to get JSON value", err.Error())
 		return
@@ -103,3 +131,270 @@ func (h *DataRedisHandler) GetJSONValue(c *gin.Context) {
 	// Return the JSON value as JSON
 	httputil.Success(c, "JSON value retrieved successfully", value)
 }
+
+// GetHashValue retrieves all fields and values of a Redis hash by its key and returns them as JSON.
+// @Summary      Get hash value from Redis
+// @Description  Get all fields and values of a Redis hash by its key
+// @Tags         dataredis
+// @Accept       json
+// @Produce      json
+// @Param        key   path      string  true  "Redis key"
+// @Success      200  {object}  HttpResponse for successful retrieval
+// @Failure      400  {object}  HttpResponse for bad request
+// @Failure      403  {object}  HttpResponse for key not allowed
+// @Failure      404  {object}  HttpResponse for not found
+// @Failure      500  {object}  HttpResponse for internal server error
+// @Failure      504  {object}  HttpResponse for Redis timeout
+// @Router       /admin/dataredis/hash/{key} [get]
+func (h *DataRedisHandler) GetHashValue(c *gin.Context) {
+	// Parse the key from the URL parameter
+	key := c.Param("key")
+	if key == "" {
+		httputil.BadRequest(c, "Invalid key", "Key cannot be empty")
+		return
+	}
+
+	// Call the service to get the hash value from Redis
+	value, err := h.Service.GetHashValue(c.Request.Context(), key)
+	if errors.Is(err, service.ErrRedisKeyNotAllowed) {
+		httputil.Forbidden(c, "Key not allowed", "Key does not start with an allow-listed prefix")
+		return
+	}
+
+	if redisutil.IsTimeout(err) {
+		httputil.GatewayTimeout(c, "Redis timed out", err.Error())
+		return
+	}
+
+	if err != nil {
+		httputil.InternalServerError(c, "Failed to get hash value", err.Error())
+		return
+	}
+
+	// An empty hash means the key does not exist
+	if len(value) == 0 {
+		httputil.NotFound(c, "Value not found", "Key does not exist in Redis")
+		return
+	}
+
+	httputil.Success(c, "Hash value retrieved successfully", value)
+}
+
+// GetListValues retrieves a range of values of a Redis list by its key and returns them as JSON.
+// @Summary      Get list values from Redis
+// @Description  Get a range of values of a Redis list by its key
+// @Tags         dataredis
+// @Accept       json
+// @Produce      json
+// @Param        key    path      string  true  "Redis key"
+// @Param        start  query     string  false "Index of the first value (default is 0)"
+// @Param        stop   query     string  false "Index of the last value, inclusive (default is 99)"
+// @Success      200  {object}  HttpResponse for successful retrieval
+// @Failure      400  {object}  HttpResponse for bad request
+// @Failure      403  {object}  HttpResponse for key not allowed
+// @Failure      404  {object}  HttpResponse for not found
+// @Failure      500  {object}  HttpResponse for internal server error
+// @Failure      504  {object}  HttpResponse for Redis timeout
+// @Router       /admin/dataredis/list/{key} [get]
+func (h *DataRedisHandler) GetListValues(c *gin.Context) {
+	// Parse the key from the URL parameter
+	key := c.Param("key")
+	if key == "" {
+		httputil.BadRequest(c, "Invalid key", "Key cannot be empty")
+		return
+	}
+
+	// Parse the range from the query string
+	start, err := strconv.ParseInt(c.DefaultQuery("start", "0"), 10, 64)
+	if err != nil {
+		httputil.BadRequest(c, "Invalid start", "Start must be an integer")
+		return
+	}
+	stop, err := strconv.ParseInt(c.DefaultQuery("stop", "99"), 10, 64)
+	if err != nil {
+		httputil.BadRequest(c, "Invalid stop", "Stop must be an integer")
+		return
+	}
+
+	// Call the service to get the list values from Redis
+	values, err := h.Service.GetListValues(c.Request.Context(), key, start, stop)
+	if errors.Is(err, service.ErrRedisKeyNotAllowed) {
+		httputil.Forbidden(c, "Key not allowed", "Key does not start with an allow-listed prefix")
+		return
+	}
+
+	if redisutil.IsTimeout(err) {
+		httputil.GatewayTimeout(c, "Redis timed out", err.Error())
+		return
+	}
+
+	if err != nil {
+		httputil.InternalServerError(c, "Failed to get list values", err.Error())
+		return
+	}
+
+	// An empty range means the key does not exist or the range is out of bounds
+	if len(values) == 0 {
+		httputil.NotFound(c, "Values not found", "Key does not exist in Redis or the range is empty")
+		return
+	}
+
+	httputil.Success(c, "List values retrieved successfully", values)
+}
+
+// GetSetMembers retrieves all members of a Redis set by its key and returns them as JSON.
+// @Summary      Get set members from Redis
+// @Description  Get all members of a Redis set by its key
+// @Tags         dataredis
+// @Accept       json
+// @Produce      json
+// @Param        key   path      string  true  "Redis key"
+// @Success      200  {object}  HttpResponse for successful retrieval
+// @Failure      400  {object}  HttpResponse for bad request
+// @Failure      403  {object}  HttpResponse for key not allowed
+// @Failure      404  {object}  HttpResponse for not found
+// @Failure      500  {object}  HttpResponse for internal server error
+// @Failure      504  {object}  HttpResponse for Redis timeout
+// @Router       /admin/dataredis/set/{key} [get]
+func (h *DataRedisHandler) GetSetMembers(c *gin.Context) {
+	// Parse the key from the URL parameter
+	key := c.Param("key")
+	if key == "" {
+		httputil.BadRequest(c, "Invalid key", "Key cannot be empty")
+		return
+	}
+
+	// Call the service to get the set members from Redis
+	members, err := h.Service.GetSetMembers(c.Request.Context(), key)
+	if errors.Is(err, service.ErrRedisKeyNotAllowed) {
+		httputil.Forbidden(c, "Key not allowed", "Key does not start with an allow-listed prefix")
+		return
+	}
+
+	if redisutil.IsTimeout(err) {
+		httputil.GatewayTimeout(c, "Redis timed out", err.Error())
+		return
+	}
+
+	if err != nil {
+		httputil.InternalServerError(c, "Failed to get set members", err.Error())
+		return
+	}
+
+	// An empty set means the key does not exist
+	if len(members) == 0 {
+		httputil.NotFound(c, "Members not found", "Key does not exist in Redis")
+		return
+	}
+
+	httputil.Success(c, "Set members retrieved successfully", members)
+}
+
+// GetTTL retrieves the remaining time to live of a Redis key and returns it as JSON.
+// The TTL is returned in seconds, or -1 if the key has no expiration.
+// @Summary      Get TTL from Redis
+// @Description  Get the remaining time to live of a Redis key in seconds
+// @Tags         dataredis
+// @Accept       json
+// @Produce      json
+// @Param        key   path      string  true  "Redis key"
+// @Success      200  {object}  HttpResponse for successful retrieval
+// @Failure      400  {object}  HttpResponse for bad request
+// @Failure      403  {object}  HttpResponse for key not allowed
+// @Failure      404  {object}  HttpResponse for not found
+// @Failure      500  {object}  HttpResponse for internal server error
+// @Failure      504  {object}  HttpResponse for Redis timeout
+// @Router       /admin/dataredis/ttl/{key} [get]
+func (h *DataRedisHandler) GetTTL(c *gin.Context) {
+	// Parse the key from the URL parameter
+	key := c.Param("key")
+	if key == "" {
+		httputil.BadRequest(c, "Invalid key", "Key cannot be empty")
+		return
+	}
+
+	// Call the service to get the TTL from Redis
+	ttl, err := h.Service.GetTTL(c.Request.Context(), key)
+	if errors.Is(err, service.ErrRedisKeyNotAllowed) {
+		httputil.Forbidden(c, "Key not allowed", "Key does not start with an allow-listed prefix")
+		return
+	}
+
+	if redisutil.IsTimeout(err) {
+		httputil.GatewayTimeout(c, "Redis timed out", err.Error())
+		return
+	}
+
+	if err != nil {
+		httputil.InternalServerError(c, "Failed to get TTL", err.Error())
+		return
+	}
+
+	// Redis reports -2 for a key that does not exist and -1 for a key without expiration
+	if ttl == -2 {
+		httputil.NotFound(c, "Key not found", "Key does not exist in Redis")
+		return
+	}
+
+	ttlSeconds := int64(-1)
+	if ttl >= 0 {
+		ttlSeconds = int64(ttl.Seconds())
+	}
+
+	httputil.Success(c, "TTL retrieved successfully", gin.H{"key": key, "ttlSeconds": ttlSeconds})
+}
+
+// ListKeys retrieves one page of Redis keys matching a pattern, with their type, TTL and memory usage, and returns them as JSON.
+// Keys are iterated with SCAN, so listing keys never blocks Redis. The returned cursor is 0 once the iteration is complete.
+// @Summary      List keys from Redis
+// @Description  List Redis keys matching a pattern using SCAN with cursor pagination
+// @Tags         dataredis
+// @Accept       json
+// @Produce      json
+// @Param        pattern  query     string  true  "Glob-style pattern, starting with an allow-listed prefix (e.g. idempotency_cache:*)"
+// @Param        cursor   query     string  false "Cursor returned by the previous page (default is 0)"
+// @Param        count    query     string  false "Number of keys to scan per page, between 1 and 1000 (default is 100)"
+// @Success      200  {object}  HttpResponse for successful retrieval
+// @Failure      400  {object}  HttpResponse for bad request
+// @Failure      403  {object}  HttpResponse for pattern not allowed
+// @Failure      500  {object}  HttpResponse for internal server error
+// @Failure      504  {object}  HttpResponse for Redis timeout
+// @Router       /admin/dataredis/keys [get]
+func (h *DataRedisHandler) ListKeys(c *gin.Context) {
+	// Parse the pattern and pagination from the query string
+	pattern := c.Query("pattern")
+	if pattern == "" {
+		httputil.BadRequest(c, "Invalid pattern", "Pattern cannot be empty")
+		return
+	}
+	cursor, err := strconv.ParseUint(c.DefaultQuery("cursor", "0"), 10, 64)
+	if err != nil {
+		httputil.BadRequest(c, "Invalid cursor", "Cursor must be a non-negative integer")
+		return
+	}
+	count, err := strconv.ParseInt(c.DefaultQuery("count", "100"), 10, 64)
+	if err != nil || count < 1 || count > 1000 {
+		httputil.BadRequest(c, "Invalid count", "Count must be an integer between 1 and 1000")
+		return
+	}
+
+	// Call the service to scan the keys in Redis
+	keys, nextCursor, err := h.Service.ListKeys(c.Request.Context(), pattern, cursor, count)
+	if errors.Is(err, service.ErrRedisKeyNotAllowed) {
+		httputil.Forbidden(c, "Pattern not allowed", "Pattern does not start with an allow-listed prefix")
+		return
+	}
+
+	if redisutil.IsTimeout(err) {
+		httputil.GatewayTimeout(c, "Redis timed out", err.Error())
+		return
+	}
+
+	if err != nil {
+		httputil.InternalServerError(c, "Failed to list keys", err.Error())
+		return
+	}
+
+	// A page may be empty while the iteration continues, so an empty page is not a not found error
+	httputil.Success(c, "Keys retrieved successfully", gin.H{"keys": keys, "cursor": nextCursor})
+}
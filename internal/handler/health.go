@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yoanesber/go-idempotency-with-redis/internal/service"
+	httputil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/http-util"
+)
+
+// This struct defines the HealthHandler which handles the liveness and readiness probes of the orchestrator.
+// It contains a service field of type HealthService which is used to check the dependencies of the service.
+type HealthHandler struct {
+	Service service.HealthService
+}
+
+// NewHealthHandler creates a new instance of HealthHandler.
+// It initializes the HealthHandler struct with the provided HealthService.
+func NewHealthHandler(healthService service.HealthService) *HealthHandler {
+	return &HealthHandler{Service: healthService}
+}
+
+// Liveness reports whether the process is alive.
+// @Summary      Liveness probe
+// @Description  Report whether the process is alive, without checking its dependencies
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  HttpResponse for a live process
+// @Router       /healthz [get]
+func (h *HealthHandler) Liveness(c *gin.Context) {
+	httputil.Success(c, "Service is alive", h.Service.Liveness())
+}
+
+// Readiness reports whether the service can serve requests, with the status and latency of each dependency.
+// @Summary      Readiness probe
+// @Description  Ping Postgres and Redis, check the validator and report whether the service can serve requests
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  HttpResponse for a ready service
+// @Failure      503  {object}  HttpResponse while a critical dependency is down or shutdown has begun
+// @Router       /readyz [get]
+func (h *HealthHandler) Readiness(c *gin.Context) {
+	report := h.Service.Readiness(c.Request.Context())
+	if report.Status != service.HealthStatusDown {
+		httputil.Success(c, "Service is ready", report)
+		return
+	}
+
+	// The report is returned with the error, so the failing dependency can be identified from the probe output
+	c.JSON(http.StatusServiceUnavailable, httputil.HttpResponse{
+		Message:   "Service is not ready",
+		Error:     "One or more critical dependencies are down, or the service is shutting down",
+		Path:      c.Request.URL.Path,
+		Status:    http.StatusServiceUnavailable,
+		Data:      report,
+		Timestamp: time.Now(),
+	})
+}
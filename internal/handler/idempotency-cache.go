@@ -0,0 +1,220 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-idempotency-with-redis/internal/entity"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/service"
+	"github.com/yoanesber/go-idempotency-with-redis/pkg/logger"
+	"github.com/yoanesber/go-idempotency-with-redis/pkg/middleware/idempotency"
+	httputil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/http-util"
+	keyutil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/key-util"
+)
+
+// This struct defines the IdempotencyCacheHandler which handles admin HTTP requests related to idempotency keys.
+// It contains a service field of type IdempotencyCacheService which is used to interact with the idempotency key data layer.
+type IdempotencyCacheHandler struct {
+	Service service.IdempotencyCacheService
+}
+
+// NewIdempotencyCacheHandler creates a new instance of IdempotencyCacheHandler.
+// It initializes the IdempotencyCacheHandler struct with the provided IdempotencyCacheService.
+func NewIdempotencyCacheHandler(idempotencyCacheService service.IdempotencyCacheService) *IdempotencyCacheHandler {
+	return &IdempotencyCacheHandler{Service: idempotencyCacheService}
+}
+
+// GetAllIdempotencyCaches retrieves a page of idempotency keys that match the filters and returns them as JSON.
+// @Summary      Get all idempotency keys
+// @Description  Get idempotency keys from the idempotency store, most recent first
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        page         query     string  false "Page number (default is 1)"
+// @Param        limit        query     string  false "Number of idempotency keys per page (default is 10)"
+// @Param        scope        query     string  false "Only keys in this scope"
+// @Param        createdFrom  query     string  false "Only keys created at or after this time (RFC 3339)"
+// @Param        createdTo    query     string  false "Only keys created at or before this time (RFC 3339)"
+// @Param        expired      query     string  false "Only expired (true) or unexpired (false) keys"
+// @Param        status       query     string  false "Only keys whose stored response has this status code"
+// @Success      200  {array}   model.HttpResponse for successful retrieval
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      401  {object}  model.HttpResponse for unauthorized
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /admin/idempotency [get]
+func (h *IdempotencyCacheHandler) GetAllIdempotencyCaches(c *gin.Context) {
+	pageStr := c.DefaultQuery("page", "1")
+	limitStr := c.DefaultQuery("limit", "10")
+
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 {
+		httputil.BadRequest(c, "Invalid page number", "Page must be a positive integer")
+		return
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 {
+		httputil.BadRequest(c, "Invalid limit", "Limit must be a positive integer")
+		return
+	}
+
+	// Parse the optional filters
+	filter := entity.IdempotencyCacheFilter{Scope: c.Query("scope")}
+	if createdFrom := c.Query("createdFrom"); createdFrom != "" {
+		t, err := time.Parse(time.RFC3339, createdFrom)
+		if err != nil {
+			httputil.BadRequest(c, "Invalid createdFrom", "createdFrom must be an RFC 3339 timestamp")
+			return
+		}
+		filter.CreatedFrom = &t
+	}
+	if createdTo := c.Query("createdTo"); createdTo != "" {
+		t, err := time.Parse(time.RFC3339, createdTo)
+		if err != nil {
+			httputil.BadRequest(c, "Invalid createdTo", "createdTo must be an RFC 3339 timestamp")
+			return
+		}
+		filter.CreatedTo = &t
+	}
+	if expiredStr := c.Query("expired"); expiredStr != "" {
+		expired, err := strconv.ParseBool(expiredStr)
+		if err != nil {
+			httputil.BadRequest(c, "Invalid expired", "expired must be true or false")
+			return
+		}
+		filter.Expired = &expired
+	}
+	if statusStr := c.Query("status"); statusStr != "" {
+		status, err := strconv.Atoi(statusStr)
+		if err != nil || status < 100 || status > 599 {
+			httputil.BadRequest(c, "Invalid status", "status must be an HTTP status code")
+			return
+		}
+		filter.StatusCode = status
+	}
+
+	idempotencyCaches, err := h.Service.GetAllIdempotencyCaches(c.Request.Context(), page, limit, filter)
+	if err != nil {
+		httputil.InternalServerError(c, "Failed to retrieve idempotency keys", err.Error())
+		return
+	}
+
+	if len(idempotencyCaches) == 0 {
+		httputil.NotFound(c, "No idempotency keys found", "No idempotency keys match the given filters")
+		return
+	}
+
+	httputil.Success(c, "All idempotency keys retrieved successfully", idempotencyCaches)
+}
+
+// GetIdempotencyCacheByKey retrieves an idempotency key by its key and scope and returns it as JSON.
+// @Summary      Get idempotency key
+// @Description  Get an idempotency key by its key and scope from the idempotency store
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        key    path      string  true  "Idempotency key"
+// @Param        scope  query     string  false "Scope of the key (default is global)"
+// @Success      200  {object}  model.HttpResponse for successful retrieval
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      401  {object}  model.HttpResponse for unauthorized
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /admin/idempotency/{key} [get]
+func (h *IdempotencyCacheHandler) GetIdempotencyCacheByKey(c *gin.Context) {
+	// Parse the key from the URL parameter and the scope from the query string
+	key, ok := parseIdempotencyKey(c)
+	if !ok {
+		return
+	}
+	scope := c.DefaultQuery("scope", idempotency.DefaultScope)
+
+	// Retrieve the idempotency key from the service
+	idempotencyCache, err := h.Service.GetIdempotencyCacheByKey(c.Request.Context(), scope, key)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httputil.NotFound(c, "Idempotency key not found", "No idempotency key found with the given key and scope")
+			return
+		}
+
+		// If the error is not a record not found error, return a generic internal server error
+		// This is to avoid exposing internal details of the error
+		httputil.InternalServerError(c, "Failed to retrieve idempotency key", err.Error())
+		return
+	}
+
+	httputil.Success(c, "Idempotency key retrieved successfully", idempotencyCache)
+}
+
+// DeleteIdempotencyCache evicts an idempotency key and its in-flight reservation from the idempotency store.
+// Every eviction is written to the audit log.
+// @Summary      Evict idempotency key
+// @Description  Evict an idempotency key and its in-flight reservation by its key and scope from the idempotency store
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        key    path      string  true  "Idempotency key"
+// @Param        scope  query     string  false "Scope of the key (default is global)"
+// @Success      200  {object}  model.HttpResponse for successful eviction
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      401  {object}  model.HttpResponse for unauthorized
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /admin/idempotency/{key} [delete]
+func (h *IdempotencyCacheHandler) DeleteIdempotencyCache(c *gin.Context) {
+	// Parse the key from the URL parameter and the scope from the query string
+	key, ok := parseIdempotencyKey(c)
+	if !ok {
+		return
+	}
+	scope := c.DefaultQuery("scope", idempotency.DefaultScope)
+
+	// Fields recorded in the audit log for the eviction
+	auditFields := log.Fields{
+		"action":     "evict_idempotency_key",
+		"scope":      scope,
+		"key":        key,
+		"client_ip":  c.ClientIP(),
+		"user_agent": c.Request.UserAgent(),
+	}
+
+	// Evict the idempotency key using the service
+	if err := h.Service.DeleteIdempotencyCache(c.Request.Context(), scope, key); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httputil.NotFound(c, "Idempotency key not found", "No idempotency key found with the given key and scope")
+			return
+		}
+
+		logger.Error(fmt.Sprintf("Audit: failed to evict idempotency key: %v", err), auditFields)
+		httputil.InternalServerError(c, "Failed to evict idempotency key", err.Error())
+		return
+	}
+
+	logger.Info("Audit: idempotency key evicted", auditFields)
+	httputil.Success(c, "Idempotency key evicted successfully", nil)
+}
+
+// parseIdempotencyKey reads the idempotency key from the URL parameter and validates it against the key policy,
+// so a malformed key is rejected with 400 Bad Request instead of failing in the idempotency store.
+// The key is normalized the same way as in the idempotency middleware. If the key is invalid, the response is written and false is returned.
+func parseIdempotencyKey(c *gin.Context) (string, bool) {
+	key := c.Param("key")
+	if key == "" {
+		httputil.BadRequest(c, "Invalid key", "Key cannot be empty")
+		return "", false
+	}
+
+	policy := keyutil.NewKeyPolicy()
+	if err := policy.Validate(key); err != nil {
+		httputil.BadRequest(c, "Invalid idempotency key", err.Error())
+		return "", false
+	}
+
+	return policy.Normalize(key), true
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"errors"
 	"strconv"
 
@@ -9,6 +10,7 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/yoanesber/go-idempotency-with-redis/internal/entity"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/processor"
 	"github.com/yoanesber/go-idempotency-with-redis/internal/service"
 	httputil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/http-util"
 	validation "github.com/yoanesber/go-idempotency-with-redis/pkg/util/validation-util"
@@ -105,9 +107,44 @@ func (h *TransactionHandler) GetTransactionByID(c *gin.Context) {
 	httputil.Success(c, "Transaction retrieved successfully", transaction)
 }
 
-// CreateTransaction creates a new transaction in the database and returns it as JSON.
+// GetTransactionHistory retrieves the status changes of a transaction, oldest first, and returns them as JSON.
+// @Summary      Get transaction history
+// @Description  Get every status change of a transaction, with its actor, reason and idempotency key
+// @Tags         transactions
+// @Produce      json
+// @Param        id   path      string  true  "Transaction ID"
+// @Success      200  {array}   model.HttpResponse for successful retrieval
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /transactions/{id}/history [get]
+func (h *TransactionHandler) GetTransactionHistory(c *gin.Context) {
+	// Parse the ID from the URL parameter
+	id := c.Param("id")
+	if id == "" {
+		httputil.BadRequest(c, "Invalid ID", "ID cannot be empty")
+		return
+	}
+
+	events, err := h.Service.GetTransactionHistory(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httputil.NotFound(c, "Transaction not found", "No transaction found with the given ID")
+			return
+		}
+
+		// If the error is not a record not found error, return a generic internal server error
+		// This is to avoid exposing internal details of the error
+		httputil.InternalServerError(c, "Failed to retrieve transaction history", err.Error())
+		return
+	}
+
+	httputil.Success(c, "Transaction history retrieved successfully", events)
+}
+
+// CreateTransaction creates a new transaction in the database, submits it to the processor and returns it as JSON.
 // @Summary      Create transaction
-// @Description  Create a new transaction in the database
+// @Description  Create a new transaction in the database and submit it to the processor; it stays pending if the submission fails
 // @Tags         transactions
 // @Accept       json
 // @Produce      json
@@ -153,3 +190,147 @@ func (h *TransactionHandler) CreateTransaction(c *gin.Context) {
 
 	httputil.Created(c, "Transaction created successfully", createdTransaction)
 }
+
+// SubmitTransaction submits a pending transaction to the downstream processor and returns it, with the status the processor replied with, as JSON.
+// @Summary      Submit transaction
+// @Description  Submit a pending transaction to the processor and move it to processing, completed or failed based on the reply
+// @Tags         transactions
+// @Produce      json
+// @Param        id   path      string  true  "Transaction ID"
+// @Success      200  {object}  model.HttpResponse for successful submission
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      409  {object}  model.HttpResponse for a transaction that is not pending or a concurrent update
+// @Failure      502  {object}  model.HttpResponse when the processor cannot be reached, rejects the request or replies with an invalid reply
+// @Failure      503  {object}  model.HttpResponse when the processor is not configured
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /transactions/{id}/submit [post]
+func (h *TransactionHandler) SubmitTransaction(c *gin.Context) {
+	// Parse the ID from the URL parameter
+	id := c.Param("id")
+	if id == "" {
+		httputil.BadRequest(c, "Invalid ID", "ID cannot be empty")
+		return
+	}
+
+	transaction, err := h.Service.SubmitTransaction(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, processor.ErrProcessorNotConfigured) {
+			httputil.ServiceUnavailable(c, "Failed to submit transaction", err.Error())
+			return
+		}
+		if errors.Is(err, processor.ErrProcessorUnavailable) || errors.Is(err, processor.ErrInvalidReply) || errors.Is(err, processor.ErrProcessorRejected) {
+			httputil.BadGateway(c, "Failed to submit transaction", err.Error())
+			return
+		}
+
+		h.respondTransitionError(c, err)
+		return
+	}
+
+	httputil.Success(c, "Transaction submitted successfully", transaction)
+}
+
+// ProcessTransaction moves a pending transaction to processing and returns it as JSON.
+// @Summary      Process transaction
+// @Description  Move a pending transaction to processing
+// @Tags         transactions
+// @Produce      json
+// @Param        id   path      string  true  "Transaction ID"
+// @Param        change  body   TransactionStatusChange  false  "Actor and reason of the status change"
+// @Success      200  {object}  model.HttpResponse for successful transition
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      409  {object}  model.HttpResponse for an invalid transition or a concurrent update
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /transactions/{id}/process [post]
+func (h *TransactionHandler) ProcessTransaction(c *gin.Context) {
+	h.transitionTransaction(c, h.Service.ProcessTransaction, "Transaction is being processed")
+}
+
+// CompleteTransaction moves a processing transaction to completed and returns it as JSON.
+// @Summary      Complete transaction
+// @Description  Move a processing transaction to completed
+// @Tags         transactions
+// @Produce      json
+// @Param        id   path      string  true  "Transaction ID"
+// @Param        change  body   TransactionStatusChange  false  "Actor and reason of the status change"
+// @Success      200  {object}  model.HttpResponse for successful transition
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      409  {object}  model.HttpResponse for an invalid transition or a concurrent update
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /transactions/{id}/complete [post]
+func (h *TransactionHandler) CompleteTransaction(c *gin.Context) {
+	h.transitionTransaction(c, h.Service.CompleteTransaction, "Transaction completed successfully")
+}
+
+// FailTransaction moves a pending or processing transaction to failed and returns it as JSON.
+// @Summary      Fail transaction
+// @Description  Move a pending or processing transaction to failed
+// @Tags         transactions
+// @Produce      json
+// @Param        id   path      string  true  "Transaction ID"
+// @Param        change  body   TransactionStatusChange  false  "Actor and reason of the status change"
+// @Success      200  {object}  model.HttpResponse for successful transition
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      409  {object}  model.HttpResponse for an invalid transition or a concurrent update
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /transactions/{id}/fail [post]
+func (h *TransactionHandler) FailTransaction(c *gin.Context) {
+	h.transitionTransaction(c, h.Service.FailTransaction, "Transaction marked as failed")
+}
+
+// transitionTransaction moves the transaction in the URL parameter to another status with the given service method,
+// and maps the errors of the state machine to HTTP responses.
+// The request body, with the actor and reason of the status change, is optional.
+func (h *TransactionHandler) transitionTransaction(c *gin.Context, transition func(ctx context.Context, id string, change entity.TransactionStatusChange) (entity.Transaction, error), message string) {
+	// Parse the ID from the URL parameter
+	id := c.Param("id")
+	if id == "" {
+		httputil.BadRequest(c, "Invalid ID", "ID cannot be empty")
+		return
+	}
+
+	var change entity.TransactionStatusChange
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&change); err != nil {
+			httputil.BadRequest(c, "Invalid request body", err.Error())
+			return
+		}
+	}
+
+	transaction, err := transition(c.Request.Context(), id, change)
+	if err != nil {
+		h.respondTransitionError(c, err)
+		return
+	}
+
+	httputil.Success(c, message, transaction)
+}
+
+// respondTransitionError maps the errors of the state machine to HTTP responses.
+func (h *TransactionHandler) respondTransitionError(c *gin.Context, err error) {
+	// Check if the error is a validation error
+	var ve validator.ValidationErrors
+	if errors.As(err, &ve) {
+		httputil.BadRequestMap(c, "Failed to update transaction status", validation.FormatValidationErrors(err))
+		return
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		httputil.NotFound(c, "Transaction not found", "No transaction found with the given ID")
+		return
+	}
+
+	if errors.Is(err, service.ErrInvalidTransactionTransition) {
+		httputil.Conflict(c, "Invalid transaction status transition", err.Error())
+		return
+	}
+
+	if errors.Is(err, service.ErrTransactionConflict) {
+		httputil.Conflict(c, "Transaction was modified concurrently", "The transaction was changed by another request, retry with the current status")
+		return
+	}
+
+	// If the error is not a known error, return a generic internal server error
+	// This is to avoid exposing internal details of the error
+	httputil.InternalServerError(c, "Failed to update transaction status", err.Error())
+}
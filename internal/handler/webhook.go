@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/go-playground/validator.v9"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-idempotency-with-redis/internal/entity"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/service"
+	httputil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/http-util"
+	validation "github.com/yoanesber/go-idempotency-with-redis/pkg/util/validation-util"
+)
+
+// This struct defines the WebhookHandler which handles the callbacks of the transaction processor.
+// It contains a service field of type TransactionService which is used to apply the reported status changes.
+type WebhookHandler struct {
+	Service service.TransactionService
+}
+
+// NewWebhookHandler creates a new instance of WebhookHandler.
+// It initializes the WebhookHandler struct with the provided TransactionService.
+func NewWebhookHandler(transactionService service.TransactionService) *WebhookHandler {
+	return &WebhookHandler{Service: transactionService}
+}
+
+// HandleTransactionWebhook applies the status change reported by the processor to the transaction and returns it as JSON.
+// The signature of the webhook is verified, and the webhook deduplicated by its event ID, before it reaches the handler.
+// @Summary      Transaction processor webhook
+// @Description  Apply a status change reported by the transaction processor
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Param        Webhook-Timestamp  header  string  true  "Time the webhook was sent, in Unix seconds"
+// @Param        Webhook-Signature  header  string  true  "HMAC-SHA256 signature of <timestamp>.<body>, as sha256=<hex>"
+// @Param        webhook  body      TransactionWebhook  true  "Status change reported by the processor"
+// @Success      200  {object}  model.HttpResponse for a successfully applied status change
+// @Failure      400  {object}  model.HttpResponse for bad request
+// @Failure      401  {object}  model.HttpResponse for an invalid signature or timestamp
+// @Failure      404  {object}  model.HttpResponse for not found
+// @Failure      409  {object}  model.HttpResponse for an invalid transition or a concurrent update
+// @Failure      500  {object}  model.HttpResponse for internal server error
+// @Router       /webhooks/transactions [post]
+func (h *WebhookHandler) HandleTransactionWebhook(c *gin.Context) {
+	// Bind the JSON request body to the TransactionWebhook struct
+	var webhook entity.TransactionWebhook
+	if err := c.ShouldBindJSON(&webhook); err != nil {
+		httputil.BadRequest(c, "Invalid request body", err.Error())
+		return
+	}
+
+	if err := webhook.Validate(); err != nil {
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			httputil.BadRequestMap(c, "Invalid webhook", validation.FormatValidationErrors(err))
+			return
+		}
+
+		httputil.BadRequest(c, "Invalid webhook", err.Error())
+		return
+	}
+
+	transaction, err := h.Service.ApplyProcessorStatus(c.Request.Context(), webhook.TransactionID, webhook.Status, webhook.Reason)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httputil.NotFound(c, "Transaction not found", "No transaction found with the given ID")
+			return
+		}
+
+		if errors.Is(err, service.ErrInvalidTransactionTransition) {
+			httputil.Conflict(c, "Invalid transaction status transition", err.Error())
+			return
+		}
+
+		if errors.Is(err, service.ErrTransactionConflict) {
+			httputil.Conflict(c, "Transaction was modified concurrently", "The transaction was changed by another request, retry the webhook")
+			return
+		}
+
+		// If the error is not a known error, return a generic internal server error
+		// This is to avoid exposing internal details of the error
+		httputil.InternalServerError(c, "Failed to apply webhook", err.Error())
+		return
+	}
+
+	httputil.Success(c, "Webhook applied successfully", transaction)
+}
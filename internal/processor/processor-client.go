@@ -0,0 +1,266 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yoanesber/go-idempotency-with-redis/internal/entity"
+	"github.com/yoanesber/go-idempotency-with-redis/pkg/logger"
+	envutil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/env-util"
+)
+
+const (
+	ProcessorActor = "processor" // Actor recorded in the history of a transaction for status changes reported by the processor
+
+	defaultProcessorTimeoutMs     = 5000  // Default timeout of each attempt, used when PROCESSOR_TIMEOUT_MS is not set
+	defaultProcessorMaxAttempts   = 5     // Default number of attempts, used when PROCESSOR_MAX_ATTEMPTS is not set
+	defaultProcessorBackoffBaseMs = 200   // Default delay before the first retry, used when PROCESSOR_BACKOFF_BASE_MS is not set
+	defaultProcessorBackoffMaxMs  = 10000 // Default upper bound of the delay between two attempts, used when PROCESSOR_BACKOFF_MAX_MS is not set
+	defaultProcessorBudgetMs      = 20000 // Default upper bound of a submission, including every retry, used when PROCESSOR_BUDGET_MS is not set
+
+	maxReplyBytes = 64 << 10 // Upper bound of the reply body read from the processor
+)
+
+var (
+	// ErrProcessorUnavailable is returned when the processor could not be reached, or kept failing, within the allowed attempts.
+	// The outcome of the submission is unknown; it is safe to submit the transaction again, as the idempotency key is forwarded.
+	ErrProcessorUnavailable = errors.New("transaction processor is unavailable")
+	// ErrInvalidReply is returned when the processor replies with a status that cannot be mapped to a transaction status.
+	ErrInvalidReply = errors.New("invalid reply from transaction processor")
+	// ErrProcessorRejected is returned when the processor rejects the request for a reason unrelated to the transaction,
+	// e.g. bad credentials (401, 403, 407) or a wrong PROCESSOR_URL (404). The transaction is left unchanged.
+	ErrProcessorRejected = errors.New("transaction processor rejected the request")
+	// ErrProcessorNotConfigured is returned when PROCESSOR_URL is not set. Transactions are never sent to a default URL.
+	ErrProcessorNotConfigured = errors.New("transaction processor is not configured, set PROCESSOR_URL")
+)
+
+// Reply is the outcome of a transaction submitted to the processor.
+type Reply struct {
+	Status string // Status of the transaction reported by the processor: processing, completed or failed
+	Reason string // Reason given by the processor, e.g. why the transaction was declined
+}
+
+// processorReply is the body of a reply from the processor.
+type processorReply struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+// replyStatuses maps the statuses reported by the processor to transaction statuses.
+var replyStatuses = map[string]string{
+	"accepted":   entity.TransactionStatusProcessing,
+	"processing": entity.TransactionStatusProcessing,
+	"completed":  entity.TransactionStatusCompleted,
+	"succeeded":  entity.TransactionStatusCompleted,
+	"failed":     entity.TransactionStatusFailed,
+	"declined":   entity.TransactionStatusFailed,
+	"rejected":   entity.TransactionStatusFailed,
+}
+
+// Interface for processor client
+// This interface defines the operation the transaction service needs to hand a transaction to the downstream processor
+type ProcessorClient interface {
+	// Submit submits the transaction to the processor and returns its reply.
+	Submit(ctx context.Context, t entity.Transaction) (Reply, error)
+}
+
+// This struct defines the HTTPProcessorClient, a processor client that posts transactions to the processor over HTTP.
+// A key derived from the idempotency scope and key of the transaction is forwarded as the downstream Idempotency-Key,
+// so a retried submission is never processed twice.
+// Network errors, timeouts and 408, 409, 425, 429 and 5xx replies are retried with exponential backoff and full jitter,
+// within the overall budget of a submission.
+type HTTPProcessorClient struct {
+	url         string
+	client      *http.Client
+	maxAttempts int
+	backoffBase time.Duration
+	backoffMax  time.Duration
+	budget      time.Duration
+}
+
+// NewProcessorClient creates the processor client that posts transactions to PROCESSOR_URL.
+// If PROCESSOR_URL is not set, the client refuses every submission with ErrProcessorNotConfigured, so transactions never leave
+// the service by accident. The timeout of each attempt, the number of attempts, the backoff and the overall budget are read from
+// the PROCESSOR_TIMEOUT_MS, PROCESSOR_MAX_ATTEMPTS, PROCESSOR_BACKOFF_BASE_MS, PROCESSOR_BACKOFF_MAX_MS and PROCESSOR_BUDGET_MS environment variables.
+func NewProcessorClient() *HTTPProcessorClient {
+	url := os.Getenv("PROCESSOR_URL")
+	if url == "" {
+		logger.Warn("PROCESSOR_URL is not set, transactions are not submitted to the processor", nil)
+	}
+
+	return &HTTPProcessorClient{
+		url:         url,
+		client:      &http.Client{Timeout: time.Duration(envutil.GetPositiveInt("PROCESSOR_TIMEOUT_MS", defaultProcessorTimeoutMs)) * time.Millisecond},
+		maxAttempts: envutil.GetPositiveInt("PROCESSOR_MAX_ATTEMPTS", defaultProcessorMaxAttempts),
+		backoffBase: time.Duration(envutil.GetPositiveInt("PROCESSOR_BACKOFF_BASE_MS", defaultProcessorBackoffBaseMs)) * time.Millisecond,
+		backoffMax:  time.Duration(envutil.GetPositiveInt("PROCESSOR_BACKOFF_MAX_MS", defaultProcessorBackoffMaxMs)) * time.Millisecond,
+		budget:      time.Duration(envutil.GetPositiveInt("PROCESSOR_BUDGET_MS", defaultProcessorBudgetMs)) * time.Millisecond,
+	}
+}
+
+// Submit posts the transaction to the processor, retrying until it gets a final reply, runs out of attempts or exceeds its budget.
+// A 2xx reply is mapped to the status it reports. A 4xx reply only fails the transaction when the processor declined it,
+// with 402, 422 or a failed status in the body; any other 4xx reply returns ErrProcessorRejected.
+func (c *HTTPProcessorClient) Submit(ctx context.Context, t entity.Transaction) (Reply, error) {
+	if c.url == "" {
+		return Reply{}, ErrProcessorNotConfigured
+	}
+
+	// Every attempt and backoff fits in the budget, or in the deadline of the caller if it is earlier
+	ctx, cancel := context.WithTimeout(ctx, c.budget)
+	defer cancel()
+
+	body, err := json.Marshal(t)
+	if err != nil {
+		return Reply{}, fmt.Errorf("failed to marshal transaction: %w", err)
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= c.maxAttempts; attempt++ {
+		reply, retryAfter, err := c.post(ctx, t, body)
+		if err == nil {
+			return reply, nil
+		}
+
+		lastErr = err
+		if retryAfter < 0 || attempt == c.maxAttempts {
+			break
+		}
+
+		delay := max(c.backoff(attempt), retryAfter)
+		logger.Warn(fmt.Sprintf("Failed to submit transaction %s to the processor (attempt %d of %d), retrying in %s: %v", t.ID, attempt, c.maxAttempts, delay, err), nil)
+
+		select {
+		case <-ctx.Done():
+			return Reply{}, fmt.Errorf("%w: %w", ErrProcessorUnavailable, ctx.Err())
+		case <-time.After(delay):
+		}
+	}
+
+	if errors.Is(lastErr, ErrInvalidReply) || errors.Is(lastErr, ErrProcessorRejected) {
+		return Reply{}, lastErr
+	}
+
+	return Reply{}, fmt.Errorf("%w: %w", ErrProcessorUnavailable, lastErr)
+}
+
+// post makes a single attempt to submit the transaction.
+// On failure, it returns the minimum delay before the next attempt, or a negative delay if the attempt must not be retried.
+func (c *HTTPProcessorClient) post(ctx context.Context, t entity.Transaction, body []byte) (Reply, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return Reply{}, -1, fmt.Errorf("failed to create processor request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", DownstreamKey(t))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return Reply{}, -1, err
+		}
+		return Reply{}, 0, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxReplyBytes))
+	if err != nil {
+		return Reply{}, 0, fmt.Errorf("failed to read processor reply: %w", err)
+	}
+
+	switch {
+	case isRetryableStatus(resp.StatusCode):
+		return Reply{}, parseRetryAfter(resp.Header.Get("Retry-After"), c.backoffMax), fmt.Errorf("processor replied with status %d", resp.StatusCode)
+	case resp.StatusCode >= 400:
+		return declinedReply(resp.StatusCode, data)
+	}
+
+	var r processorReply
+	if err := json.Unmarshal(data, &r); err != nil {
+		return Reply{}, -1, fmt.Errorf("%w: %w", ErrInvalidReply, err)
+	}
+
+	status, ok := replyStatuses[strings.ToLower(r.Status)]
+	if !ok {
+		return Reply{}, -1, fmt.Errorf("%w: unknown status %q", ErrInvalidReply, r.Status)
+	}
+
+	return Reply{Status: status, Reason: r.Reason}, 0, nil
+}
+
+// DownstreamKey returns the Idempotency-Key forwarded to the processor for the transaction.
+// Idempotency keys are only unique within their scope, so the key is the SHA-256 hash of the scope and the key;
+// two consumers using the same key never share a downstream key.
+func DownstreamKey(t entity.Transaction) string {
+	sum := sha256.Sum256([]byte(t.IdempotencyCacheScope + "\x00" + t.IdempotencyCacheKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// backoff returns a random delay between zero and the exponential backoff of the given attempt (full jitter),
+// so clients retrying at the same time do not hit the processor in lockstep.
+func (c *HTTPProcessorClient) backoff(attempt int) time.Duration {
+	delay := c.backoffBase
+	for i := 1; i < attempt && delay < c.backoffMax; i++ {
+		delay *= 2
+	}
+	delay = min(delay, c.backoffMax)
+
+	if delay <= 0 {
+		return 0
+	}
+	return rand.N(delay + 1)
+}
+
+// isRetryableStatus reports whether a reply with the given status code may succeed if the request is retried.
+// 409 is retried because processors reply with it while a request with the same idempotency key is in flight.
+func isRetryableStatus(code int) bool {
+	switch code {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooEarly, http.StatusTooManyRequests:
+		return true
+	}
+
+	return code >= 500
+}
+
+// parseRetryAfter parses the Retry-After header, in seconds, and caps it at the given upper bound.
+// It returns zero if the header is missing or is not a number of seconds.
+func parseRetryAfter(value string, upper time.Duration) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+
+	return min(time.Duration(seconds)*time.Second, upper)
+}
+
+// declinedReply maps a non-retryable 4xx reply to the failed status, if the processor declined the transaction.
+// The decline is explicit: a failed status in the body, or 402 Payment Required or 422 Unprocessable Entity.
+// Any other 4xx reply, such as 401, 403, 404 or 407, is caused by our request or configuration, so it returns ErrProcessorRejected.
+func declinedReply(code int, data []byte) (Reply, time.Duration, error) {
+	var r processorReply
+	_ = json.Unmarshal(data, &r)
+
+	declined := replyStatuses[strings.ToLower(r.Status)] == entity.TransactionStatusFailed
+	if !declined && code != http.StatusPaymentRequired && code != http.StatusUnprocessableEntity {
+		return Reply{}, -1, fmt.Errorf("%w: status %d", ErrProcessorRejected, code)
+	}
+
+	reason := r.Reason
+	if reason == "" {
+		reason = fmt.Sprintf("declined by the processor with status %d", code)
+	}
+
+	return Reply{Status: entity.TransactionStatusFailed, Reason: reason}, 0, nil
+}
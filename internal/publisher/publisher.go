@@ -0,0 +1,38 @@
+package publisher
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Message is an event published to a topic.
+// The idempotency key of the request that produced the event travels with it, so consumers can dedupe redeliveries.
+type Message struct {
+	ID                    string    // ID of the outbox message, stable across redeliveries
+	Topic                 string    // Topic of the event, e.g. payment-event
+	AggregateID           string    // ID of the entity the event is about, e.g. the transaction
+	IdempotencyCacheScope string    // Scope of the idempotency key of the request that produced the event
+	IdempotencyCacheKey   string    // Idempotency key of the request that produced the event
+	Payload               string    // JSON payload of the event
+	CreatedAt             time.Time // Time the event was written to the outbox
+}
+
+// Interface for publisher
+// This interface defines the operation the outbox relay needs to deliver an event to a message broker
+type Publisher interface {
+	// Publish delivers the message to its topic. A message may be published more than once.
+	Publish(ctx context.Context, msg Message) error
+}
+
+// NewPublisher creates the publisher used by the outbox relay.
+// Events are published to Redis Streams, trimmed to OUTBOX_STREAM_MAX_LEN entries when it is set.
+func NewPublisher() Publisher {
+	maxLen, err := strconv.ParseInt(os.Getenv("OUTBOX_STREAM_MAX_LEN"), 10, 64)
+	if err != nil || maxLen < 0 {
+		maxLen = 0
+	}
+
+	return NewRedisStreamPublisher(maxLen)
+}
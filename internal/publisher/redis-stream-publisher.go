@@ -0,0 +1,40 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	redisutil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/redis-util"
+)
+
+// This struct defines the RedisStreamPublisher, a publisher that appends each message to the Redis stream named after its topic (XADD).
+// Each entry carries the outbox message ID and the idempotency key, so consumers can dedupe redeliveries.
+type RedisStreamPublisher struct {
+	maxLen int64
+}
+
+// NewRedisStreamPublisher creates a new instance of RedisStreamPublisher.
+// If maxLen is positive, each stream is trimmed to approximately maxLen entries; otherwise streams are not trimmed.
+func NewRedisStreamPublisher(maxLen int64) *RedisStreamPublisher {
+	return &RedisStreamPublisher{maxLen: maxLen}
+}
+
+// Publish appends the message to the stream of its topic.
+func (p *RedisStreamPublisher) Publish(ctx context.Context, msg Message) error {
+	values := map[string]interface{}{
+		"id":                    msg.ID,
+		"topic":                 msg.Topic,
+		"aggregateId":           msg.AggregateID,
+		"idempotencyCacheScope": msg.IdempotencyCacheScope,
+		"idempotencyCacheKey":   msg.IdempotencyCacheKey,
+		"payload":               msg.Payload,
+		"createdAt":             msg.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+
+	if _, err := redisutil.AddToStream(ctx, msg.Topic, values, p.maxLen); err != nil {
+		return fmt.Errorf("failed to publish message %s to stream %s: %w", msg.ID, msg.Topic, err)
+	}
+
+	return nil
+}
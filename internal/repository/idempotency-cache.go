@@ -2,6 +2,7 @@ package repository
 
 import (
 	"fmt"
+	"time"
 
 	"gorm.io/gorm"
 
@@ -11,10 +12,12 @@ import (
 // Interface for idempotency key repository
 // This interface defines the methods that the idempotency key repository should implement
 type IdempotencyCacheRepository interface {
-	GetAllIdempotencyCaches(tx *gorm.DB) ([]entity.IdempotencyCache, error)
-	GetIdempotencyCacheByKey(tx *gorm.DB, key string) (entity.IdempotencyCache, error)
+	GetAllIdempotencyCaches(tx *gorm.DB, page int, limit int, filter entity.IdempotencyCacheFilter) ([]entity.IdempotencyCache, error)
+	GetIdempotencyCacheByKey(tx *gorm.DB, scope string, key string) (entity.IdempotencyCache, error)
 	CreateIdempotencyCache(tx *gorm.DB, key entity.IdempotencyCache) (entity.IdempotencyCache, error)
 	UpdateIdempotencyCache(tx *gorm.DB, key entity.IdempotencyCache) (entity.IdempotencyCache, error)
+	DeleteIdempotencyCache(tx *gorm.DB, scope string, key string) error
+	DeleteExpiredIdempotencyCaches(tx *gorm.DB, before time.Time, limit int) (int64, error)
 }
 
 // This struct defines the IdempotencyCacheRepository that contains methods for interacting with the database
@@ -27,11 +30,36 @@ func NewIdempotencyCacheRepository() IdempotencyCacheRepository {
 	return &idempotencyCacheRepository{}
 }
 
-// GetAllIdempotencyCaches retrieves all idempotency keys from the database.
-func (r *idempotencyCacheRepository) GetAllIdempotencyCaches(tx *gorm.DB) ([]entity.IdempotencyCache, error) {
-	// Select all idempotency keys from the database
+// GetAllIdempotencyCaches retrieves a page of idempotency keys that match the filter from the database.
+// The most recently created keys come first.
+func (r *idempotencyCacheRepository) GetAllIdempotencyCaches(tx *gorm.DB, page int, limit int, filter entity.IdempotencyCacheFilter) ([]entity.IdempotencyCache, error) {
+	// Apply the filters that are set
+	query := tx.Model(&entity.IdempotencyCache{})
+	if filter.Scope != "" {
+		query = query.Where("scope = ?", filter.Scope)
+	}
+	if filter.CreatedFrom != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		query = query.Where("created_at <= ?", *filter.CreatedTo)
+	}
+	if filter.Expired != nil && *filter.Expired {
+		query = query.Where("expired_at <= ?", time.Now())
+	}
+	if filter.Expired != nil && !*filter.Expired {
+		query = query.Where("expired_at > ?", time.Now())
+	}
+	if filter.StatusCode != 0 {
+		query = query.Where("status_code = ?", filter.StatusCode)
+	}
+
+	// Select the requested page of idempotency keys from the database
 	var idempotencyCaches []entity.IdempotencyCache
-	err := tx.Find(&idempotencyCaches).Error
+	err := query.Order("created_at DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&idempotencyCaches).Error
 	if err != nil {
 		return nil, err
 	}
@@ -39,11 +67,11 @@ func (r *idempotencyCacheRepository) GetAllIdempotencyCaches(tx *gorm.DB) ([]ent
 	return idempotencyCaches, nil
 }
 
-// GetIdempotencyCacheByKey retrieves an idempotency key by its key string from the database.
-func (r *idempotencyCacheRepository) GetIdempotencyCacheByKey(tx *gorm.DB, key string) (entity.IdempotencyCache, error) {
-	// Select the idempotency key with the given key string from the database
+// GetIdempotencyCacheByKey retrieves an idempotency key by its scope and key string from the database.
+func (r *idempotencyCacheRepository) GetIdempotencyCacheByKey(tx *gorm.DB, scope string, key string) (entity.IdempotencyCache, error) {
+	// Select the idempotency key with the given scope and key string from the database
 	var idempotencyCache entity.IdempotencyCache
-	err := tx.First(&idempotencyCache, "key = ?", key).Error
+	err := tx.First(&idempotencyCache, "scope = ? AND key = ?", scope, key).Error
 	if err != nil {
 		return entity.IdempotencyCache{}, err
 	}
@@ -70,3 +98,32 @@ func (r *idempotencyCacheRepository) UpdateIdempotencyCache(tx *gorm.DB, key ent
 
 	return key, nil
 }
+
+// DeleteIdempotencyCache deletes an idempotency key by its scope and key string from the database.
+func (r *idempotencyCacheRepository) DeleteIdempotencyCache(tx *gorm.DB, scope string, key string) error {
+	// Delete the idempotency key with the given scope and key string from the database
+	if err := tx.Where("scope = ? AND key = ?", scope, key).Delete(&entity.IdempotencyCache{}).Error; err != nil {
+		return fmt.Errorf("failed to delete idempotency cache: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExpiredIdempotencyCaches deletes at most limit idempotency keys that expired before the given time.
+// It returns the number of deleted keys, so callers can purge a large backlog in bounded batches.
+func (r *idempotencyCacheRepository) DeleteExpiredIdempotencyCaches(tx *gorm.DB, before time.Time, limit int) (int64, error) {
+	// Select the oldest expired keys first, limited to the batch size
+	expired := tx.Model(&entity.IdempotencyCache{}).
+		Select("scope", "key").
+		Where("expired_at < ?", before).
+		Order("expired_at").
+		Limit(limit)
+
+	// Delete the selected keys from the database
+	result := tx.Where("(scope, key) IN (?)", expired).Delete(&entity.IdempotencyCache{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete expired idempotency caches: %w", result.Error)
+	}
+
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/yoanesber/go-idempotency-with-redis/internal/entity"
+)
+
+// Interface for idempotency lock repository
+// This interface defines the methods that the idempotency lock repository should implement
+type IdempotencyLockRepository interface {
+	GetIdempotencyLock(tx *gorm.DB, scope string, key string) (entity.IdempotencyLock, error)
+	CreateIdempotencyLock(tx *gorm.DB, lock entity.IdempotencyLock) (bool, error)
+	DeleteIdempotencyLock(tx *gorm.DB, scope string, key string, token string) error
+	DeleteExpiredIdempotencyLocks(tx *gorm.DB, before time.Time) (int64, error)
+}
+
+// This struct defines the IdempotencyLockRepository that contains methods for interacting with the database
+// It implements the IdempotencyLockRepository interface and provides methods for idempotency lock-related operations
+type idempotencyLockRepository struct{}
+
+// NewIdempotencyLockRepository creates a new instance of IdempotencyLockRepository.
+// It initializes the idempotencyLockRepository struct and returns it.
+func NewIdempotencyLockRepository() IdempotencyLockRepository {
+	return &idempotencyLockRepository{}
+}
+
+// GetIdempotencyLock retrieves the reservation of an idempotency key by its scope and key string from the database.
+func (r *idempotencyLockRepository) GetIdempotencyLock(tx *gorm.DB, scope string, key string) (entity.IdempotencyLock, error) {
+	// Select the reservation with the given scope and key string from the database
+	var lock entity.IdempotencyLock
+	err := tx.First(&lock, "scope = ? AND key = ?", scope, key).Error
+	if err != nil {
+		return entity.IdempotencyLock{}, err
+	}
+
+	return lock, nil
+}
+
+// CreateIdempotencyLock creates the reservation of an idempotency key in the database, unless it already exists.
+// It returns whether the reservation was created.
+func (r *idempotencyLockRepository) CreateIdempotencyLock(tx *gorm.DB, lock entity.IdempotencyLock) (bool, error) {
+	// Insert the reservation, leaving an existing reservation untouched
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&lock)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to create idempotency lock: %w", result.Error)
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// DeleteIdempotencyLock deletes the reservation of an idempotency key if it is held by the given token.
+func (r *idempotencyLockRepository) DeleteIdempotencyLock(tx *gorm.DB, scope string, key string, token string) error {
+	// Delete the reservation with the given scope, key string and token from the database
+	if err := tx.Where("scope = ? AND key = ? AND token = ?", scope, key, token).Delete(&entity.IdempotencyLock{}).Error; err != nil {
+		return fmt.Errorf("failed to delete idempotency lock: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExpiredIdempotencyLocks deletes the reservations whose lease expired before the given time.
+// It returns the number of deleted reservations.
+func (r *idempotencyLockRepository) DeleteExpiredIdempotencyLocks(tx *gorm.DB, before time.Time) (int64, error) {
+	// Delete the expired reservations from the database
+	result := tx.Where("expired_at < ?", before).Delete(&entity.IdempotencyLock{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete expired idempotency locks: %w", result.Error)
+	}
+
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/yoanesber/go-idempotency-with-redis/internal/entity"
+)
+
+// Interface for outbox message repository
+// This interface defines the methods that the outbox message repository should implement
+type OutboxMessageRepository interface {
+	CreateOutboxMessage(tx *gorm.DB, m entity.OutboxMessage) (entity.OutboxMessage, error)
+	ClaimPendingOutboxMessages(tx *gorm.DB, now time.Time, limit int) ([]entity.OutboxMessage, error)
+	UpdateOutboxMessage(tx *gorm.DB, m entity.OutboxMessage) error
+}
+
+// This struct defines the outboxMessageRepository that implements the OutboxMessageRepository interface.
+// It contains methods for interacting with the outbox in the database.
+type outboxMessageRepository struct{}
+
+// NewOutboxMessageRepository creates a new instance of OutboxMessageRepository.
+// It initializes the outboxMessageRepository struct and returns it.
+func NewOutboxMessageRepository() OutboxMessageRepository {
+	return &outboxMessageRepository{}
+}
+
+// CreateOutboxMessage writes a message to the outbox and returns the created message.
+func (r *outboxMessageRepository) CreateOutboxMessage(tx *gorm.DB, m entity.OutboxMessage) (entity.OutboxMessage, error) {
+	if err := tx.Create(&m).Error; err != nil {
+		return entity.OutboxMessage{}, fmt.Errorf("failed to create outbox message: %w", err)
+	}
+
+	return m, nil
+}
+
+// ClaimPendingOutboxMessages locks and returns the pending messages that are due for delivery, oldest first.
+// Messages locked by another relay are skipped (FOR UPDATE SKIP LOCKED), so replicas never deliver the same message concurrently.
+// The locks are held until the given database transaction ends.
+func (r *outboxMessageRepository) ClaimPendingOutboxMessages(tx *gorm.DB, now time.Time, limit int) ([]entity.OutboxMessage, error) {
+	var messages []entity.OutboxMessage
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("status = ? AND next_attempt_at <= ?", entity.OutboxStatusPending, now).
+		Order("next_attempt_at ASC").
+		Limit(limit).
+		Find(&messages).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim pending outbox messages: %w", err)
+	}
+
+	return messages, nil
+}
+
+// UpdateOutboxMessage saves the delivery status, attempts, last error and next attempt time of a message.
+func (r *outboxMessageRepository) UpdateOutboxMessage(tx *gorm.DB, m entity.OutboxMessage) error {
+	err := tx.Model(&entity.OutboxMessage{}).
+		Where("id = ?", m.ID).
+		Updates(map[string]interface{}{
+			"status":          m.Status,
+			"attempts":        m.Attempts,
+			"last_error":      m.LastError,
+			"next_attempt_at": m.NextAttemptAt,
+			"delivered_at":    m.DeliveredAt,
+		}).Error
+
+	if err != nil {
+		return fmt.Errorf("failed to update outbox message: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-idempotency-with-redis/internal/entity"
+)
+
+// Interface for transaction event repository
+// This interface defines the methods that the transaction event repository should implement
+type TransactionEventRepository interface {
+	GetTransactionEvents(tx *gorm.DB, transactionID string) ([]entity.TransactionEvent, error)
+	CreateTransactionEvent(tx *gorm.DB, e entity.TransactionEvent) (entity.TransactionEvent, error)
+}
+
+// This struct defines the transactionEventRepository that implements the TransactionEventRepository interface.
+// It contains methods for interacting with the transaction event data in the database.
+type transactionEventRepository struct{}
+
+// NewTransactionEventRepository creates a new instance of TransactionEventRepository.
+// It initializes the transactionEventRepository struct and returns it.
+func NewTransactionEventRepository() TransactionEventRepository {
+	return &transactionEventRepository{}
+}
+
+// GetTransactionEvents retrieves the status changes of a transaction from the database, oldest first.
+func (r *transactionEventRepository) GetTransactionEvents(tx *gorm.DB, transactionID string) ([]entity.TransactionEvent, error) {
+	var events []entity.TransactionEvent
+	err := tx.Where("transaction_id = ?", transactionID).
+		Order("created_at ASC").
+		Find(&events).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// CreateTransactionEvent records a status change of a transaction in the database and returns the created event.
+func (r *transactionEventRepository) CreateTransactionEvent(tx *gorm.DB, e entity.TransactionEvent) (entity.TransactionEvent, error) {
+	if err := tx.Create(&e).Error; err != nil {
+		return entity.TransactionEvent{}, fmt.Errorf("failed to create transaction event: %w", err)
+	}
+
+	return e, nil
+}
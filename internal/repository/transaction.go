@@ -2,6 +2,7 @@ package repository
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/yoanesber/go-idempotency-with-redis/internal/entity"
 	"gorm.io/gorm" // Import GORM for ORM functionalities
@@ -13,6 +14,7 @@ type TransactionRepository interface {
 	GetAllTransactions(tx *gorm.DB, page int, limit int) ([]entity.Transaction, error)
 	GetTransactionByID(tx *gorm.DB, id string) (entity.Transaction, error)
 	CreateTransaction(tx *gorm.DB, d entity.Transaction) (entity.Transaction, error)
+	UpdateTransactionStatus(tx *gorm.DB, t entity.Transaction, status string) (int64, error)
 }
 
 // This struct defines the transactionRepository that implements the TransactionRepository interface.
@@ -95,3 +97,22 @@ func (r *transactionRepository) CreateTransaction(tx *gorm.DB, t entity.Transact
 
 	return t, nil
 }
+
+// UpdateTransactionStatus changes the status of the transaction, provided it still has the status and version it was read with.
+// The version is incremented, so a concurrent update based on the same read changes no rows.
+// It returns the number of updated rows, which is 0 if the transaction was changed concurrently.
+func (r *transactionRepository) UpdateTransactionStatus(tx *gorm.DB, t entity.Transaction, status string) (int64, error) {
+	result := tx.Model(&entity.Transaction{}).
+		Where("id = ? AND status = ? AND version = ?", t.ID, t.Status, t.Version).
+		Updates(map[string]interface{}{
+			"status":     status,
+			"version":    gorm.Expr("version + 1"),
+			"updated_at": time.Now(),
+		})
+
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to update transaction status: %w", result.Error)
+	}
+
+	return result.RowsAffected, nil
+}
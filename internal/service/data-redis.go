@@ -1,28 +1,62 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
 	redisutil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/redis-util"
 )
 
+// ErrRedisKeyNotAllowed is returned when a Redis key does not start with one of the allow-listed prefixes.
+var ErrRedisKeyNotAllowed = errors.New("redis key is not allowed")
+
 // Interface for the DataRedisService
 // This interface defines the methods that the DataRedisService should implement
 type DataRedisService interface {
-	GetStringValue(key string) (string, error)
-	GetJSONValue(key string) (interface{}, error)
+	GetStringValue(ctx context.Context, key string) (string, error)
+	GetJSONValue(ctx context.Context, key string) (interface{}, error)
+	GetHashValue(ctx context.Context, key string) (map[string]string, error)
+	GetListValues(ctx context.Context, key string, start int64, stop int64) ([]string, error)
+	GetSetMembers(ctx context.Context, key string) ([]string, error)
+	GetTTL(ctx context.Context, key string) (time.Duration, error)
+	ListKeys(ctx context.Context, pattern string, cursor uint64, count int64) ([]redisutil.KeyInfo, uint64, error)
 }
 
 // This struct defines the DataRedisService
-type dataRedisService struct{}
+// It contains the key prefixes that may be read, so the service cannot be used to read arbitrary data.
+type dataRedisService struct {
+	allowedPrefixes []string
+}
 
 // NewDataRedisService creates a new instance of DataRedisService
-// It initializes the dataRedisService struct and returns it.
+// The allow-listed key prefixes are read from the DATAREDIS_ALLOWED_PREFIXES environment variable (comma-separated),
+// and default to IDEMPOTENCY_PREFIX. It initializes the dataRedisService struct and returns it.
 func NewDataRedisService() DataRedisService {
-	return &dataRedisService{}
+	prefixes := os.Getenv("DATAREDIS_ALLOWED_PREFIXES")
+	if prefixes == "" {
+		prefixes = os.Getenv("IDEMPOTENCY_PREFIX")
+	}
+
+	var allowedPrefixes []string
+	for _, prefix := range strings.Split(prefixes, ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			allowedPrefixes = append(allowedPrefixes, prefix)
+		}
+	}
+
+	return &dataRedisService{allowedPrefixes: allowedPrefixes}
 }
 
 // GetStringValue retrieves a string value from Redis by its key
-func (s *dataRedisService) GetStringValue(key string) (string, error) {
-	value, err := redisutil.Get(key)
+func (s *dataRedisService) GetStringValue(ctx context.Context, key string) (string, error) {
+	if !s.isAllowed(key) {
+		return "", ErrRedisKeyNotAllowed
+	}
+
+	value, err := redisutil.Get(ctx, key)
 	if err != nil {
 		return "", err
 	}
@@ -31,11 +65,87 @@ func (s *dataRedisService) GetStringValue(key string) (string, error) {
 }
 
 // GetJSONValue retrieves a JSON value from Redis by its key
-func (s *dataRedisService) GetJSONValue(key string) (interface{}, error) {
-	value, err := redisutil.GetJSON[any](key)
+func (s *dataRedisService) GetJSONValue(ctx context.Context, key string) (interface{}, error) {
+	if !s.isAllowed(key) {
+		return nil, ErrRedisKeyNotAllowed
+	}
+
+	value, err := redisutil.GetJSON[any](ctx, key)
 	if err != nil {
 		return nil, err
 	}
 
 	return value, nil
 }
+
+// GetHashValue retrieves all fields and values of a Redis hash by its key
+// It returns an empty map if the key does not exist
+func (s *dataRedisService) GetHashValue(ctx context.Context, key string) (map[string]string, error) {
+	if !s.isAllowed(key) {
+		return nil, ErrRedisKeyNotAllowed
+	}
+
+	return redisutil.GetAllHash(ctx, key)
+}
+
+// GetListValues retrieves a range of values of a Redis list by its key
+// It returns an empty slice if the key does not exist
+func (s *dataRedisService) GetListValues(ctx context.Context, key string, start int64, stop int64) ([]string, error) {
+	if !s.isAllowed(key) {
+		return nil, ErrRedisKeyNotAllowed
+	}
+
+	return redisutil.GetListRange(ctx, key, start, stop)
+}
+
+// GetSetMembers retrieves all members of a Redis set by its key
+// It returns an empty slice if the key does not exist
+func (s *dataRedisService) GetSetMembers(ctx context.Context, key string) ([]string, error) {
+	if !s.isAllowed(key) {
+		return nil, ErrRedisKeyNotAllowed
+	}
+
+	return redisutil.GetSetMembers(ctx, key)
+}
+
+// GetTTL retrieves the remaining time to live of a Redis key
+// It returns a negative duration if the key has no expiration or does not exist
+func (s *dataRedisService) GetTTL(ctx context.Context, key string) (time.Duration, error) {
+	if !s.isAllowed(key) {
+		return 0, ErrRedisKeyNotAllowed
+	}
+
+	return redisutil.GetTTL(ctx, key)
+}
+
+// ListKeys retrieves one page of keys matching the pattern, with their type, TTL and memory usage
+// It uses SCAN, so it never blocks Redis; the returned cursor is 0 once the iteration is complete
+// The pattern must start with an allow-listed prefix, so keys outside the allow-list are never listed
+func (s *dataRedisService) ListKeys(ctx context.Context, pattern string, cursor uint64, count int64) ([]redisutil.KeyInfo, uint64, error) {
+	if !s.isAllowed(pattern) {
+		return nil, 0, ErrRedisKeyNotAllowed
+	}
+
+	keys, nextCursor, err := redisutil.ScanKeys(ctx, pattern, cursor, count)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	infos, err := redisutil.DescribeKeys(ctx, keys)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return infos, nextCursor, nil
+}
+
+// isAllowed reports whether the key starts with one of the allow-listed prefixes
+func (s *dataRedisService) isAllowed(key string) bool {
+	for _, prefix := range s.allowedPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yoanesber/go-idempotency-with-redis/config/cache"
+	"github.com/yoanesber/go-idempotency-with-redis/config/database"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/store"
+	envutil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/env-util"
+	validation "github.com/yoanesber/go-idempotency-with-redis/pkg/util/validation-util"
+)
+
+const (
+	HealthStatusUp       = "UP"       // The service, or the dependency, is healthy
+	HealthStatusDown     = "DOWN"     // The service, or the dependency, is unavailable
+	HealthStatusDegraded = "DEGRADED" // A non-critical dependency is unavailable, but the service can still serve requests
+
+	defaultHealthCheckTimeoutMs = 1000 // Default timeout of each dependency check, used when HEALTH_CHECK_TIMEOUT_MS is not set
+)
+
+// HealthCheck checks a single dependency of the service.
+// A critical dependency that is down makes the service not ready; a non-critical one only degrades it.
+type HealthCheck struct {
+	Name     string
+	Critical bool
+	Check    func(ctx context.Context) error
+	Details  func() map[string]string // Optional details reported with the result, e.g. the circuit breaker state
+}
+
+// DependencyHealth is the result of checking a single dependency.
+type DependencyHealth struct {
+	Name      string            `json:"name"`
+	Status    string            `json:"status"`
+	Critical  bool              `json:"critical"`
+	LatencyMs float64           `json:"latencyMs"`
+	Error     string            `json:"error,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+// HealthReport is the health of the service and of each of its dependencies.
+type HealthReport struct {
+	Status       string             `json:"status"`
+	ShuttingDown bool               `json:"shuttingDown"`
+	Dependencies []DependencyHealth `json:"dependencies,omitempty"`
+}
+
+// Interface for the HealthService
+// This interface defines the methods that the HealthService should implement
+type HealthService interface {
+	Liveness() HealthReport
+	Readiness(ctx context.Context) HealthReport
+	MarkShuttingDown()
+}
+
+// This struct defines the HealthService
+// It contains the dependency checks and whether the graceful shutdown has begun
+type healthService struct {
+	checks       []HealthCheck
+	timeout      time.Duration
+	shuttingDown atomic.Bool
+}
+
+// NewHealthService creates a new instance of HealthService with the given dependency checks.
+// Each check is bounded by HEALTH_CHECK_TIMEOUT_MS. It initializes the healthService struct and returns it.
+func NewHealthService(checks ...HealthCheck) HealthService {
+	timeoutMs := envutil.GetPositiveInt("HEALTH_CHECK_TIMEOUT_MS", defaultHealthCheckTimeoutMs)
+
+	return &healthService{checks: checks, timeout: time.Duration(timeoutMs) * time.Millisecond}
+}
+
+// DefaultHealthChecks returns the checks of the dependencies of the service: Postgres, Redis and the validator.
+// Redis is not critical with the FAIL_OVER policy of the Redis idempotency store, as requests then fall back to Postgres.
+func DefaultHealthChecks() []HealthCheck {
+	return []HealthCheck{
+		{
+			Name:     "postgres",
+			Critical: true,
+			Check:    database.PingPostgres,
+		},
+		{
+			Name:     "redis",
+			Critical: os.Getenv("IDEMPOTENCY_REDIS_FAILURE_POLICY") != store.FailOver,
+			Check:    cache.PingRedis,
+			Details: func() map[string]string {
+				return map[string]string{"circuitState": cache.GetRedisCircuitState()}
+			},
+		},
+		{
+			Name:     "validator",
+			Critical: true,
+			Check: func(ctx context.Context) error {
+				if !validation.IsInitialized() {
+					return fmt.Errorf("validator is not initialized")
+				}
+				return nil
+			},
+		},
+	}
+}
+
+// Liveness reports whether the process is alive. It does not check the dependencies,
+// so an outage of a dependency never gets the process restarted.
+func (s *healthService) Liveness() HealthReport {
+	return HealthReport{Status: HealthStatusUp, ShuttingDown: s.shuttingDown.Load()}
+}
+
+// Readiness checks every dependency concurrently and reports whether the service can serve requests.
+// The service is not ready once the graceful shutdown has begun, or while a critical dependency is down.
+func (s *healthService) Readiness(ctx context.Context) HealthReport {
+	results := make([]DependencyHealth, len(s.checks))
+
+	var wg sync.WaitGroup
+	for i, check := range s.checks {
+		wg.Add(1)
+		go func(i int, check HealthCheck) {
+			defer wg.Done()
+			results[i] = s.runCheck(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := HealthReport{Status: HealthStatusUp, ShuttingDown: s.shuttingDown.Load(), Dependencies: results}
+	for _, result := range results {
+		if result.Status == HealthStatusUp {
+			continue
+		}
+
+		if result.Critical {
+			report.Status = HealthStatusDown
+		} else if report.Status == HealthStatusUp {
+			report.Status = HealthStatusDegraded
+		}
+	}
+
+	if report.ShuttingDown {
+		report.Status = HealthStatusDown
+	}
+
+	return report
+}
+
+// MarkShuttingDown marks the service as shutting down, so it is reported as not ready and receives no new traffic.
+func (s *healthService) MarkShuttingDown() {
+	s.shuttingDown.Store(true)
+}
+
+// runCheck runs a single dependency check within the check timeout and measures its latency.
+func (s *healthService) runCheck(ctx context.Context, check HealthCheck) DependencyHealth {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.Check(ctx)
+
+	result := DependencyHealth{
+		Name:      check.Name,
+		Status:    HealthStatusUp,
+		Critical:  check.Critical,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+
+	if err != nil {
+		result.Status = HealthStatusDown
+		result.Error = err.Error()
+	}
+
+	if check.Details != nil {
+		result.Details = check.Details()
+	}
+
+	return result
+}
@@ -2,207 +2,144 @@ package service
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
-	"strconv"
 	"time"
 
 	"gorm.io/gorm"
 
-	"github.com/yoanesber/go-idempotency-with-redis/config/database"
 	"github.com/yoanesber/go-idempotency-with-redis/internal/entity"
 	"github.com/yoanesber/go-idempotency-with-redis/internal/repository"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/store"
 	metacontext "github.com/yoanesber/go-idempotency-with-redis/pkg/context-data/meta-context"
-	redisutil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/redis-util"
-)
-
-const (
-	ttl_hour = 24 // Time to live for idempotency keys in Redis, set to 24 hours
 )
 
 // Interface for idempotency key service
 // This interface defines the methods that the idempotency key service should implement
 type IdempotencyCacheService interface {
-	GetAllIdempotencyCaches() ([]entity.IdempotencyCache, error)
-	GetIdempotencyCacheByKey(key string) (entity.IdempotencyCache, error)
-	CreateIdempotencyCache(ctx context.Context, responsePayload interface{}) (entity.IdempotencyCache, error)
-	UpdateIdempotencyCache(key string, responsePayload interface{}) (entity.IdempotencyCache, error)
+	GetAllIdempotencyCaches(ctx context.Context, page int, limit int, filter entity.IdempotencyCacheFilter) ([]entity.IdempotencyCache, error)
+	GetIdempotencyCacheByKey(ctx context.Context, scope string, key string) (entity.IdempotencyCache, error)
+	DeleteIdempotencyCache(ctx context.Context, scope string, key string) error
+	ClaimIdempotencyCache(ctx context.Context, tx *gorm.DB) (entity.IdempotencyCache, error)
 }
 
 // This struct defines the IdempotencyCacheService that contains a repository field of type IdempotencyCacheRepository
+// and the idempotency store used by the idempotency middleware.
 // It implements the IdempotencyCacheService interface and provides methods for idempotency key-related operations
 type idempotencyCacheService struct {
-	repo repository.IdempotencyCacheRepository
+	repo      repository.IdempotencyCacheRepository
+	idemStore store.IdempotencyStore
 }
 
-// NewIdempotencyCacheService creates a new instance of IdempotencyCacheService with the given repository.
+// NewIdempotencyCacheService creates a new instance of IdempotencyCacheService with the given repository and idempotency store.
+// Keys are inspected and evicted through the store, so the admin API works on the same backend as the middleware;
+// the repository is only used by ClaimIdempotencyCache, within the caller's database transaction.
 // It initializes the idempotencyCacheService struct and returns it.
-func NewIdempotencyCacheService(repo repository.IdempotencyCacheRepository) IdempotencyCacheService {
-	return &idempotencyCacheService{repo: repo}
+func NewIdempotencyCacheService(repo repository.IdempotencyCacheRepository, idemStore store.IdempotencyStore) IdempotencyCacheService {
+	return &idempotencyCacheService{repo: repo, idemStore: idemStore}
 }
 
-// GetAllIdempotencyCaches retrieves all idempotency keys from the database.
-func (s *idempotencyCacheService) GetAllIdempotencyCaches() ([]entity.IdempotencyCache, error) {
-	db := database.GetPostgres()
-	if db == nil {
-		return nil, fmt.Errorf("database connection is nil")
-	}
-
-	// Retrieve all idempotency keys from the repository
-	idempotencyCaches, err := s.repo.GetAllIdempotencyCaches(db)
-	if err != nil {
-		return nil, err
+// GetAllIdempotencyCaches retrieves a page of idempotency keys that match the filter from the idempotency store.
+func (s *idempotencyCacheService) GetAllIdempotencyCaches(ctx context.Context, page int, limit int, filter entity.IdempotencyCacheFilter) ([]entity.IdempotencyCache, error) {
+	if s.idemStore == nil {
+		return nil, fmt.Errorf("idempotency store is nil")
 	}
 
-	return idempotencyCaches, nil
+	return s.idemStore.List(ctx, page, limit, filter)
 }
 
-// GetIdempotencyCacheByKey retrieves an idempotency key by its key from the database.
-func (s *idempotencyCacheService) GetIdempotencyCacheByKey(key string) (entity.IdempotencyCache, error) {
-	db := database.GetPostgres()
-	if db == nil {
-		return entity.IdempotencyCache{}, fmt.Errorf("database connection is nil")
+// GetIdempotencyCacheByKey retrieves an idempotency key by its scope and key from the idempotency store.
+// It returns gorm.ErrRecordNotFound if the key does not exist.
+func (s *idempotencyCacheService) GetIdempotencyCacheByKey(ctx context.Context, scope string, key string) (entity.IdempotencyCache, error) {
+	if s.idemStore == nil {
+		return entity.IdempotencyCache{}, fmt.Errorf("idempotency store is nil")
 	}
 
-	// Retrieve the idempotency key by key from the repository
-	idempotencyCache, err := s.repo.GetIdempotencyCacheByKey(db, key)
+	idempotencyCache, err := s.idemStore.Inspect(ctx, scope, key)
 	if err != nil {
 		return entity.IdempotencyCache{}, err
 	}
 
-	return idempotencyCache, nil
+	if idempotencyCache == nil {
+		return entity.IdempotencyCache{}, gorm.ErrRecordNotFound
+	}
+
+	return *idempotencyCache, nil
 }
 
-// CreateIdempotencyCache creates a new idempotency key in the database.
-func (s *idempotencyCacheService) CreateIdempotencyCache(ctx context.Context, responsePayload interface{}) (entity.IdempotencyCache, error) {
-	db := database.GetPostgres()
-	if db == nil {
-		return entity.IdempotencyCache{}, fmt.Errorf("database connection is nil")
+// DeleteIdempotencyCache evicts an idempotency key and its in-flight reservation from the idempotency store.
+// A retry with the key is processed again afterwards. It returns gorm.ErrRecordNotFound if the key has neither a record nor a reservation.
+func (s *idempotencyCacheService) DeleteIdempotencyCache(ctx context.Context, scope string, key string) error {
+	if s.idemStore == nil {
+		return fmt.Errorf("idempotency store is nil")
 	}
 
-	// Extract the idempotency key and body hash from the context
-	meta, ok := metacontext.ExtractIdemCompetencyMeta(ctx)
-	if !ok {
-		return entity.IdempotencyCache{}, fmt.Errorf("idempotency metadata not found in context")
+	evicted, err := s.idemStore.Evict(ctx, scope, key)
+	if err != nil {
+		return err
 	}
 
-	// Create a new idempotency key object
-	idemKey := meta.Key
-	bodyHash := meta.BodyHash
-	resp, err := json.Marshal(responsePayload)
-	if err != nil {
-		return entity.IdempotencyCache{}, fmt.Errorf("failed to marshal response payload: %w", err)
+	if !evicted {
+		return gorm.ErrRecordNotFound
 	}
 
-	// Convert the response payload to JSON string
-	respStr := string(resp)
+	return nil
+}
 
-	// Create a new IdempotencyCache object
-	now := time.Now()
-	idemData := entity.IdempotencyCache{
-		Key:             idemKey,
-		BodyHash:        bodyHash,
-		ResponsePayload: respStr,
-		CreatedAt:       now,
+// ClaimIdempotencyCache records the idempotency key in the database within the caller's transaction.
+// It is an opt-in hook for services that need the idempotency record to be committed atomically with their own data.
+// The record is created without a response; the response is stored later by the idempotency store of the middleware.
+func (s *idempotencyCacheService) ClaimIdempotencyCache(ctx context.Context, tx *gorm.DB) (entity.IdempotencyCache, error) {
+	if tx == nil {
+		return entity.IdempotencyCache{}, fmt.Errorf("transaction is nil")
 	}
 
-	// Set the expiration time for the idempotency key
-	ttlStr := os.Getenv("IDEMPOTENCY_TTL_HOURS")
-	ttl, err := strconv.Atoi(ttlStr)
-	if err != nil {
-		return entity.IdempotencyCache{}, fmt.Errorf("invalid Idempotency TTL hours: %w", err)
+	// Extract the idempotency key and body hash from the context
+	meta, ok := metacontext.ExtractIdemCompetencyMeta(ctx)
+	if !ok {
+		return entity.IdempotencyCache{}, fmt.Errorf("idempotency metadata not found in context")
 	}
-	idemData.ExpiredAt = now.Add(time.Duration(ttl) * time.Hour)
-
-	createdIdemData := entity.IdempotencyCache{}
-	err = db.Transaction(func(tx *gorm.DB) error {
-		// Check if the idempotency key already exists
-		existingIdem, err := s.repo.GetIdempotencyCacheByKey(tx, idemKey)
-		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-			return err
-		}
-
-		// If the key already exists, return an error
-		if existingIdem.Key != "" {
-			return fmt.Errorf("idempotency key %s already exists", idemKey)
-		}
 
-		// Create the new idempotency key
-		createdIdemData, err = s.repo.CreateIdempotencyCache(tx, idemData)
-		if err != nil {
-			return err
-		}
-
-		// Store the idempotency key and body hash in Redis with a TTL
-		ttl := time.Duration(ttl_hour * time.Hour)
-		idemPrefix := os.Getenv("IDEMPOTENCY_PREFIX")
-		redisKey := idemPrefix + idemKey
-		if err := redisutil.SetJSON(redisKey, createdIdemData, ttl); err != nil {
-			return fmt.Errorf("failed to set idempotency key in Redis: %w", err)
-		}
-
-		return nil
-	})
-
-	if err != nil {
+	// Check if the idempotency key already exists
+	existingIdem, err := s.repo.GetIdempotencyCacheByKey(tx, meta.Scope, meta.Key)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		return entity.IdempotencyCache{}, err
 	}
 
-	return createdIdemData, nil
-}
-
-// UpdateIdempotencyCache updates an existing idempotency key in the database.
-func (s *idempotencyCacheService) UpdateIdempotencyCache(key string, responsePayload interface{}) (entity.IdempotencyCache, error) {
-	db := database.GetPostgres()
-	if db == nil {
-		return entity.IdempotencyCache{}, fmt.Errorf("database connection is nil")
-	}
-
-	// Convert the response payload to JSON string
-	resp, err := json.Marshal(responsePayload)
-	if err != nil {
-		return entity.IdempotencyCache{}, fmt.Errorf("failed to marshal response payload: %w", err)
-	}
-	respStr := string(resp)
-
-	updatedIdemData := entity.IdempotencyCache{}
-	err = db.Transaction(func(tx *gorm.DB) error {
-		// Retrieve the existing idempotency key
-		existingIdem, err := s.repo.GetIdempotencyCacheByKey(db, key)
-		if err != nil {
-			return err
+	// If the key already exists, return an error, unless it has expired and can be reused
+	if existingIdem.Key != "" {
+		if !existingIdem.IsExpired() {
+			return entity.IdempotencyCache{}, fmt.Errorf("idempotency key %s already exists in scope %s", meta.Key, meta.Scope)
 		}
 
-		if existingIdem.Key == "" {
-			return fmt.Errorf("idempotency key %s does not exist", key)
+		if err := s.repo.DeleteIdempotencyCache(tx, meta.Scope, meta.Key); err != nil {
+			return entity.IdempotencyCache{}, err
 		}
+	}
 
-		// Update the idempotency key with the new response payload
-		existingIdem.ResponsePayload = respStr
-		existingIdem.UpdatedAt = time.Now()
-
-		// Update the idempotency key in the database
-		updatedIdemData, err = s.repo.UpdateIdempotencyCache(tx, existingIdem)
-		if err != nil {
-			return err
-		}
+	// The expiration time is set by the TTL policy of the middleware
+	if meta.ExpiredAt.IsZero() {
+		return entity.IdempotencyCache{}, fmt.Errorf("idempotency expiration time not found in context")
+	}
 
-		// Update the idempotency key in Redis with a TTL
-		ttl := time.Duration(ttl_hour * time.Hour)
-		idemPrefix := os.Getenv("IDEMPOTENCY_PREFIX")
-		redisKey := idemPrefix + key
-		if err := redisutil.SetJSON(redisKey, updatedIdemData, ttl); err != nil {
-			return fmt.Errorf("failed to update idempotency key in Redis: %w", err)
-		}
+	// Create the new idempotency key without a response
+	idemData := newIdempotencyCache(meta, time.Now())
 
-		return nil
-	})
+	return s.repo.CreateIdempotencyCache(tx, idemData)
+}
 
-	if err != nil {
-		return entity.IdempotencyCache{}, err
+// newIdempotencyCache creates an idempotency cache entry, without a response, from the request fingerprint
+// and expiration time in the metadata.
+func newIdempotencyCache(meta metacontext.IdemCompetencyMeta, now time.Time) entity.IdempotencyCache {
+	return entity.IdempotencyCache{
+		Scope:         meta.Scope,
+		Key:           meta.Key,
+		RequestMethod: meta.RequestMethod,
+		RequestPath:   meta.RequestPath,
+		QueryHash:     meta.QueryHash,
+		HeadersHash:   meta.HeadersHash,
+		BodyHash:      meta.BodyHash,
+		CreatedAt:     now,
+		ExpiredAt:     meta.ExpiredAt,
 	}
-
-	return updatedIdemData, nil
 }
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/yoanesber/go-idempotency-with-redis/internal/entity"
+)
+
+var (
+	// ErrInvalidTransactionTransition is returned when a transaction cannot move from its current status to the requested one.
+	ErrInvalidTransactionTransition = errors.New("invalid transaction status transition")
+	// ErrTransactionConflict is returned when the transaction was changed concurrently, e.g. by another worker.
+	ErrTransactionConflict = errors.New("transaction was modified concurrently")
+)
+
+// transactionTransitions lists the statuses a transaction can move to from each status.
+// A pending transaction is either picked up for processing or rejected; a processing transaction either completes or fails.
+// Completed and failed are final.
+var transactionTransitions = map[string][]string{
+	entity.TransactionStatusPending:    {entity.TransactionStatusProcessing, entity.TransactionStatusFailed},
+	entity.TransactionStatusProcessing: {entity.TransactionStatusCompleted, entity.TransactionStatusFailed},
+}
+
+// CanTransitionTransaction reports whether a transaction can move from one status to another.
+func CanTransitionTransaction(from string, to string) bool {
+	for _, status := range transactionTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// transactionStatusStages orders the statuses along the lifecycle of a transaction. Completed and failed are both final.
+var transactionStatusStages = map[string]int{
+	entity.TransactionStatusPending:    0,
+	entity.TransactionStatusProcessing: 1,
+	entity.TransactionStatusCompleted:  2,
+	entity.TransactionStatusFailed:     2,
+}
+
+// IsStaleTransactionStatus reports whether a status reported for a transaction is stale: the transaction is already final,
+// or has moved past the reported status. A report of the current status is not stale.
+func IsStaleTransactionStatus(current string, reported string) bool {
+	if current == reported {
+		return false
+	}
+
+	return len(transactionTransitions[current]) == 0 || transactionStatusStages[reported] < transactionStatusStages[current]
+}
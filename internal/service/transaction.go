@@ -2,14 +2,19 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 
 	"gorm.io/gorm"
 
 	"github.com/yoanesber/go-idempotency-with-redis/config/database"
 	"github.com/yoanesber/go-idempotency-with-redis/internal/entity"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/processor"
 	"github.com/yoanesber/go-idempotency-with-redis/internal/repository"
 	metacontext "github.com/yoanesber/go-idempotency-with-redis/pkg/context-data/meta-context"
+	"github.com/yoanesber/go-idempotency-with-redis/pkg/logger"
 )
 
 const (
@@ -17,27 +22,48 @@ const (
 	withdrawalEventTopic   = "withdrawal-event"
 	disbursementEventTopic = "disbursement-event"
 
-	externalServiceURL = "https://example.com/api/transactions" // Example external service URL
+	lockLeaseMargin = time.Second // Time kept between the end of a submission and the expiry of the in-flight lock of the request
 )
 
+// transactionEventTopics maps each transaction type to the topic its events are published to.
+var transactionEventTopics = map[string]string{
+	entity.TransactionTypePayment:      paymentEventTopic,
+	entity.TransactionTypeWithdrawal:   withdrawalEventTopic,
+	entity.TransactionTypeDisbursement: disbursementEventTopic,
+}
+
 // Interface for transaction service
 // This interface defines the methods that the transaction service should implement
 type TransactionService interface {
 	GetAllTransactions(page int, limit int) ([]entity.Transaction, error)
 	GetTransactionByID(id string) (entity.Transaction, error)
+	GetTransactionHistory(id string) ([]entity.TransactionEvent, error)
 	CreateTransaction(ctx context.Context, t entity.Transaction) (entity.Transaction, error)
+	SubmitTransaction(ctx context.Context, id string) (entity.Transaction, error)
+	ApplyProcessorStatus(ctx context.Context, id string, status string, reason string) (entity.Transaction, error)
+	ProcessTransaction(ctx context.Context, id string, change entity.TransactionStatusChange) (entity.Transaction, error)
+	CompleteTransaction(ctx context.Context, id string, change entity.TransactionStatusChange) (entity.Transaction, error)
+	FailTransaction(ctx context.Context, id string, change entity.TransactionStatusChange) (entity.Transaction, error)
 }
 
 // This struct defines the TransactionService that contains a repository field of type TransactionRepository
 // It implements the TransactionService interface and provides methods for transaction-related operations
 type transactionService struct {
-	repo repository.TransactionRepository
+	repo       repository.TransactionRepository
+	eventRepo  repository.TransactionEventRepository
+	outboxRepo repository.OutboxMessageRepository
+	processor  processor.ProcessorClient
 }
 
-// NewTransactionService creates a new instance of TransactionService with the given repository.
+// NewTransactionService creates a new instance of TransactionService with the given repositories and processor client.
+// If the processor client is nil, transactions are submitted to PROCESSOR_URL; without it, they are never submitted.
 // This function initializes the transactionService struct and returns it.
-func NewTransactionService(repo repository.TransactionRepository) TransactionService {
-	return &transactionService{repo: repo}
+func NewTransactionService(repo repository.TransactionRepository, eventRepo repository.TransactionEventRepository, outboxRepo repository.OutboxMessageRepository, processorClient processor.ProcessorClient) TransactionService {
+	if processorClient == nil {
+		processorClient = processor.NewProcessorClient()
+	}
+
+	return &transactionService{repo: repo, eventRepo: eventRepo, outboxRepo: outboxRepo, processor: processorClient}
 }
 
 // GetAllTransactions retrieves all transactions from the database.
@@ -72,7 +98,28 @@ func (s *transactionService) GetTransactionByID(id string) (entity.Transaction,
 	return transaction, nil
 }
 
-// CreateTransaction creates a new transaction in the database.
+// GetTransactionHistory retrieves the status changes of a transaction from the database, oldest first.
+// It returns gorm.ErrRecordNotFound if the transaction does not exist.
+func (s *transactionService) GetTransactionHistory(id string) ([]entity.TransactionEvent, error) {
+	db := database.GetPostgres()
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	// Check if the transaction exists, so an unknown ID is not reported as an empty history
+	if _, err := s.repo.GetTransactionByID(db, id); err != nil {
+		return nil, err
+	}
+
+	events, err := s.eventRepo.GetTransactionEvents(db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// CreateTransaction creates a new transaction in the database and submits it to the processor.
 // It validates the transaction struct and checks if the ID already exists before creating a new transaction.
 func (s *transactionService) CreateTransaction(ctx context.Context, t entity.Transaction) (entity.Transaction, error) {
 	db := database.GetPostgres()
@@ -86,7 +133,8 @@ func (s *transactionService) CreateTransaction(ctx context.Context, t entity.Tra
 		return entity.Transaction{}, fmt.Errorf("idempotency meta not found in context")
 	}
 
-	// Set the idempotency cache key in the transaction
+	// Set the idempotency cache scope and key in the transaction
+	t.IdempotencyCacheScope = meta.Scope
 	t.IdempotencyCacheKey = meta.Key
 
 	// Validate the transaction struct using the validator
@@ -122,10 +170,28 @@ func (s *transactionService) CreateTransaction(ctx context.Context, t entity.Tra
 			return err
 		}
 
-		// Save idempotency cache in the database
+		// Record the creation of the transaction as the first event of its history
+		_, err = s.eventRepo.CreateTransactionEvent(tx, entity.TransactionEvent{
+			TransactionID:         createdTransaction.ID,
+			ToStatus:              createdTransaction.Status,
+			Actor:                 createdTransaction.ConsumerID,
+			IdempotencyCacheScope: meta.Scope,
+			IdempotencyCacheKey:   meta.Key,
+		})
+		if err != nil {
+			return err
+		}
+
+		// Write the event of the transaction to the outbox, so it is published if, and only if, the transaction is committed
+		if err := s.writeOutboxMessage(tx, createdTransaction); err != nil {
+			return err
+		}
+
+		// Claim the idempotency key in the same database transaction
+		// The response is stored by the idempotency middleware once the request completes
 		idemRepo := repository.NewIdempotencyCacheRepository()
-		idemService := NewIdempotencyCacheService(idemRepo)
-		if _, err := idemService.CreateIdempotencyCache(ctx, createdTransaction); err != nil {
+		idemService := NewIdempotencyCacheService(idemRepo, nil) // Only the claim hook is used, which needs no idempotency store
+		if _, err := idemService.ClaimIdempotencyCache(ctx, tx); err != nil {
 			return err
 		}
 
@@ -136,5 +202,189 @@ func (s *transactionService) CreateTransaction(ctx context.Context, t entity.Tra
 		return entity.Transaction{}, err
 	}
 
-	return createdTransaction, nil
+	// Hand the created transaction to the processor once it is committed
+	// The creation succeeds even if the submission fails: the transaction stays pending and can be submitted again
+	submittedTransaction, err := s.SubmitTransaction(ctx, createdTransaction.ID)
+	if err != nil {
+		if !errors.Is(err, processor.ErrProcessorNotConfigured) {
+			logger.Warn(fmt.Sprintf("Failed to submit transaction %s to the processor, it stays pending: %v", createdTransaction.ID, err), nil)
+		}
+		return createdTransaction, nil
+	}
+
+	return submittedTransaction, nil
+}
+
+// writeOutboxMessage writes the created transaction to the outbox, under the topic of its type.
+// The outbox relay publishes the message after the database transaction is committed.
+func (s *transactionService) writeOutboxMessage(tx *gorm.DB, t entity.Transaction) error {
+	topic, ok := transactionEventTopics[t.Type]
+	if !ok {
+		return fmt.Errorf("no event topic for transaction type %q", t.Type)
+	}
+
+	payload, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("failed to marshal transaction event: %w", err)
+	}
+
+	_, err = s.outboxRepo.CreateOutboxMessage(tx, entity.OutboxMessage{
+		Topic:                 topic,
+		AggregateID:           t.ID,
+		IdempotencyCacheScope: t.IdempotencyCacheScope,
+		IdempotencyCacheKey:   t.IdempotencyCacheKey,
+		Payload:               string(payload),
+		Status:                entity.OutboxStatusPending,
+	})
+
+	return err
+}
+
+// SubmitTransaction hands a pending transaction to the downstream processor and applies the status it replies with.
+// The transaction stays pending if the processor cannot be reached, so it can be submitted again; the processor dedupes
+// the submissions by the idempotency key of the transaction.
+func (s *transactionService) SubmitTransaction(ctx context.Context, id string) (entity.Transaction, error) {
+	transaction, err := s.GetTransactionByID(id)
+	if err != nil {
+		return entity.Transaction{}, err
+	}
+
+	if transaction.Status != entity.TransactionStatusPending {
+		return entity.Transaction{}, fmt.Errorf("%w: only pending transactions can be submitted, transaction is %s", ErrInvalidTransactionTransition, transaction.Status)
+	}
+
+	// The submission must end while the request holds its idempotency lock,
+	// otherwise a client retry could submit the transaction again in parallel
+	submitCtx, cancel := withinLockLease(ctx)
+	defer cancel()
+
+	reply, err := s.processor.Submit(submitCtx, transaction)
+	if err != nil {
+		return entity.Transaction{}, err
+	}
+
+	return s.ApplyProcessorStatus(ctx, id, reply.Status, reply.Reason)
+}
+
+// withinLockLease bounds the context by the in-flight lock of the idempotent request, less a safety margin.
+// The context is returned unchanged, with a cancel function, if the request holds no idempotency lock.
+func withinLockLease(ctx context.Context) (context.Context, context.CancelFunc) {
+	meta, ok := metacontext.ExtractIdemCompetencyMeta(ctx)
+	if !ok || meta.LockExpiresAt.IsZero() {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithDeadline(ctx, meta.LockExpiresAt.Add(-lockLeaseMargin))
+}
+
+// ApplyProcessorStatus moves the transaction to the status reported by the processor, in its reply or in a webhook.
+// A status the transaction already has, or has moved past (e.g. processing reported after completed), is not applied,
+// and the transaction is returned unchanged. Webhooks can be delivered more than once and out of order, so such a report
+// is acknowledged rather than rejected, otherwise the processor would keep redelivering it.
+// A pending transaction reported as completed goes through processing first, so its history shows every step.
+func (s *transactionService) ApplyProcessorStatus(ctx context.Context, id string, status string, reason string) (entity.Transaction, error) {
+	transaction, err := s.GetTransactionByID(id)
+	if err != nil {
+		return entity.Transaction{}, err
+	}
+
+	if transaction.Status == status {
+		return transaction, nil
+	}
+
+	if IsStaleTransactionStatus(transaction.Status, status) {
+		logger.Warn(fmt.Sprintf("Ignoring stale processor status %s for transaction %s, which is %s", status, id, transaction.Status), nil)
+		return transaction, nil
+	}
+
+	if status == entity.TransactionStatusCompleted && transaction.Status == entity.TransactionStatusPending {
+		if _, err := s.transitionTransaction(ctx, id, entity.TransactionStatusProcessing, entity.TransactionStatusChange{Actor: processor.ProcessorActor}); err != nil {
+			return entity.Transaction{}, err
+		}
+	}
+
+	return s.transitionTransaction(ctx, id, status, entity.TransactionStatusChange{Actor: processor.ProcessorActor, Reason: reason})
+}
+
+// ProcessTransaction moves a pending transaction to processing.
+func (s *transactionService) ProcessTransaction(ctx context.Context, id string, change entity.TransactionStatusChange) (entity.Transaction, error) {
+	return s.transitionTransaction(ctx, id, entity.TransactionStatusProcessing, change)
+}
+
+// CompleteTransaction moves a processing transaction to completed.
+func (s *transactionService) CompleteTransaction(ctx context.Context, id string, change entity.TransactionStatusChange) (entity.Transaction, error) {
+	return s.transitionTransaction(ctx, id, entity.TransactionStatusCompleted, change)
+}
+
+// FailTransaction moves a pending or processing transaction to failed.
+func (s *transactionService) FailTransaction(ctx context.Context, id string, change entity.TransactionStatusChange) (entity.Transaction, error) {
+	return s.transitionTransaction(ctx, id, entity.TransactionStatusFailed, change)
+}
+
+// transitionTransaction moves the transaction to the given status, if the state machine allows it.
+// The update is conditional on the status and version the transaction was read with (optimistic concurrency),
+// so when two workers move the same transaction at the same time, only one succeeds and the other gets ErrTransactionConflict.
+// The status change is recorded in the history of the transaction in the same database transaction as the update.
+func (s *transactionService) transitionTransaction(ctx context.Context, id string, status string, change entity.TransactionStatusChange) (entity.Transaction, error) {
+	db := database.GetPostgres()
+	if db == nil {
+		return entity.Transaction{}, fmt.Errorf("database connection is nil")
+	}
+	db = db.WithContext(ctx)
+
+	if err := change.Validate(); err != nil {
+		return entity.Transaction{}, err
+	}
+
+	if change.Actor == "" {
+		change.Actor = entity.TransactionEventActorSystem
+	}
+
+	// The idempotency key is optional here, as the history is also written by requests without one
+	meta, _ := metacontext.ExtractIdemCompetencyMeta(ctx)
+
+	updatedTransaction := entity.Transaction{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Retrieve the current status and version of the transaction
+		transaction, err := s.repo.GetTransactionByID(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if !CanTransitionTransaction(transaction.Status, status) {
+			return fmt.Errorf("%w: %s to %s", ErrInvalidTransactionTransition, transaction.Status, status)
+		}
+
+		// Update the status, unless the transaction has been changed since it was read
+		updated, err := s.repo.UpdateTransactionStatus(tx, transaction, status)
+		if err != nil {
+			return err
+		}
+
+		if updated == 0 {
+			return ErrTransactionConflict
+		}
+
+		_, err = s.eventRepo.CreateTransactionEvent(tx, entity.TransactionEvent{
+			TransactionID:         id,
+			FromStatus:            transaction.Status,
+			ToStatus:              status,
+			Actor:                 change.Actor,
+			Reason:                change.Reason,
+			IdempotencyCacheScope: meta.Scope,
+			IdempotencyCacheKey:   meta.Key,
+		})
+		if err != nil {
+			return err
+		}
+
+		updatedTransaction, err = s.repo.GetTransactionByID(tx, id)
+		return err
+	})
+
+	if err != nil {
+		return entity.Transaction{}, err
+	}
+
+	return updatedTransaction, nil
 }
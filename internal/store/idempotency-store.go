@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/yoanesber/go-idempotency-with-redis/internal/entity"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/repository"
+)
+
+const (
+	StoreRedis    = "REDIS"    // Redis for lookups and in-flight locks, backed by Postgres for durability
+	StorePostgres = "POSTGRES" // Postgres only, for deployments without Redis
+	StoreMemory   = "MEMORY"   // In-memory, for tests and single-node deployments
+)
+
+// Reservation is the result of reserving an idempotency key for an in-flight request.
+type Reservation struct {
+	Token      string        // Token that releases the reservation; empty if the key was not reserved
+	Acquired   bool          // Whether the key was reserved for the current request
+	RetryAfter time.Duration // Remaining lease of the reservation held by another request
+}
+
+// Interface for idempotency store
+// This interface defines the operations the idempotency middleware needs to reserve, store, replay and purge idempotency keys
+// Every operation takes the caller's context, so a cancelled or timed-out request stops waiting on the backing store
+type IdempotencyStore interface {
+	// Reserve atomically reserves the key for the current request for the duration of the lease.
+	Reserve(ctx context.Context, scope string, key string, lease time.Duration) (Reservation, error)
+	// Get returns the unexpired record of the key, or nil if the key has never been seen or has expired.
+	Get(ctx context.Context, scope string, key string) (*entity.IdempotencyCache, error)
+	// Complete stores the response of the request; an existing record keeps its original expiration time.
+	Complete(ctx context.Context, record entity.IdempotencyCache) (entity.IdempotencyCache, error)
+	// Discard discards the record of the key if it has no stored response, e.g. a claim left behind by a crashed request.
+	// It is only called by the holder of the reservation, so the record cannot belong to a request in flight.
+	Discard(ctx context.Context, scope string, key string) error
+	// Release releases the reservation held by the token and discards the record of the key if it has no stored response.
+	Release(ctx context.Context, scope string, key string, token string) error
+	// Purge deletes expired keys in batches of the given size and returns the number of deleted keys.
+	Purge(ctx context.Context, batchSize int) (int64, error)
+	// List returns a page of records that match the filter, most recent first, including expired records and records without a response.
+	List(ctx context.Context, page int, limit int, filter entity.IdempotencyCacheFilter) ([]entity.IdempotencyCache, error)
+	// Inspect returns the record of the key, including an expired record or one without a response, or nil if the key has no record.
+	Inspect(ctx context.Context, scope string, key string) (*entity.IdempotencyCache, error)
+	// Evict deletes the record of the key and its reservation, so a retry with the key is processed again.
+	// It reports whether the key had a record or a reservation.
+	Evict(ctx context.Context, scope string, key string) (bool, error)
+}
+
+// NewIdempotencyStore creates the idempotency store selected by the IDEMPOTENCY_STORE environment variable.
+// It defaults to the Redis store, backed by Postgres, with the failure policy in IDEMPOTENCY_REDIS_FAILURE_POLICY.
+func NewIdempotencyStore() IdempotencyStore {
+	switch os.Getenv("IDEMPOTENCY_STORE") {
+	case StorePostgres:
+		return NewPostgresStore(repository.NewIdempotencyCacheRepository(), repository.NewIdempotencyLockRepository())
+	case StoreMemory:
+		return NewMemoryStore()
+	default:
+		return NewRedisStore(NewPostgresStore(repository.NewIdempotencyCacheRepository(), repository.NewIdempotencyLockRepository()), os.Getenv("IDEMPOTENCY_REDIS_FAILURE_POLICY"))
+	}
+}
+
+// scopedKey returns the identifier of an idempotency key within its scope, used in Redis keys and by the memory store.
+// The scope is prefixed with its length, as both the scope and the key may contain the separator; otherwise scope "a:b"
+// with key "c" and scope "a" with key "b:c" would share one record.
+func scopedKey(scope string, key string) string {
+	return strconv.Itoa(len(scope)) + ":" + scope + ":" + key
+}
+
+// newToken generates a random token that identifies the holder of a reservation.
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate lock token: %w", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// completeRecord merges the response of the request into the existing record of the key.
+// A new record is used if the key does not exist yet or has expired; otherwise the existing record
+// keeps its creation and expiration time, so storing the response never extends its lifetime.
+func completeRecord(existing *entity.IdempotencyCache, record entity.IdempotencyCache, now time.Time) entity.IdempotencyCache {
+	if existing != nil && !existing.IsExpired() {
+		record.CreatedAt = existing.CreatedAt
+		record.ExpiredAt = existing.ExpiredAt
+	} else {
+		record.CreatedAt = now
+	}
+
+	record.UpdatedAt = now
+	return record
+}
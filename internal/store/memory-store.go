@@ -0,0 +1,246 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yoanesber/go-idempotency-with-redis/internal/entity"
+)
+
+// memoryLock is an in-flight reservation held by the MemoryStore.
+type memoryLock struct {
+	token     string
+	expiredAt time.Time
+}
+
+// This struct defines the MemoryStore, an idempotency store that keeps records and reservations in process memory.
+// It is intended for tests and single-node deployments; records are lost when the process restarts.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]entity.IdempotencyCache
+	locks   map[string]memoryLock
+}
+
+// NewMemoryStore creates a new, empty instance of MemoryStore.
+// It initializes the MemoryStore struct and returns it.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records: make(map[string]entity.IdempotencyCache),
+		locks:   make(map[string]memoryLock),
+	}
+}
+
+// Reserve reserves the key, unless another request holds an unexpired reservation.
+func (s *MemoryStore) Reserve(ctx context.Context, scope string, key string, lease time.Duration) (Reservation, error) {
+	token, err := newToken()
+	if err != nil {
+		return Reservation{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := memoryID(scope, key)
+	if lock, ok := s.locks[id]; ok && lock.expiredAt.After(time.Now()) {
+		return Reservation{RetryAfter: time.Until(lock.expiredAt)}, nil
+	}
+
+	s.locks[id] = memoryLock{token: token, expiredAt: time.Now().Add(lease)}
+	return Reservation{Token: token, Acquired: true}, nil
+}
+
+// Get returns the unexpired record of the key.
+func (s *MemoryStore) Get(ctx context.Context, scope string, key string) (*entity.IdempotencyCache, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[memoryID(scope, key)]
+	if !ok || record.IsExpired() {
+		return nil, nil
+	}
+
+	return &record, nil
+}
+
+// Complete stores the response of the request.
+func (s *MemoryStore) Complete(ctx context.Context, record entity.IdempotencyCache) (entity.IdempotencyCache, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := memoryID(record.Scope, record.Key)
+	var existing *entity.IdempotencyCache
+	if r, ok := s.records[id]; ok {
+		existing = &r
+	}
+
+	saved := completeRecord(existing, record, time.Now())
+	s.records[id] = saved
+	return saved, nil
+}
+
+// Discard discards the record of the key if it has no stored response.
+func (s *MemoryStore) Discard(ctx context.Context, scope string, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := memoryID(scope, key)
+	if record, ok := s.records[id]; ok && !record.IsCompleted() {
+		delete(s.records, id)
+	}
+
+	return nil
+}
+
+// Release releases the reservation held by the token and discards the record of the key if it has no stored response.
+func (s *MemoryStore) Release(ctx context.Context, scope string, key string, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := memoryID(scope, key)
+	if record, ok := s.records[id]; ok && !record.IsCompleted() {
+		delete(s.records, id)
+	}
+
+	if lock, ok := s.locks[id]; ok && lock.token == token {
+		delete(s.locks, id)
+	}
+
+	return nil
+}
+
+// Purge deletes expired reservations, and then expired records in batches of the given size.
+// The store is unlocked between batches, so requests are not blocked for the whole purge,
+// and the purge stops between batches once the context is cancelled.
+func (s *MemoryStore) Purge(ctx context.Context, batchSize int) (int64, error) {
+	if batchSize <= 0 {
+		return 0, fmt.Errorf("batch size must be a positive integer")
+	}
+
+	now := time.Now()
+	s.purgeLocks(now)
+
+	// Delete expired records until a batch comes back smaller than the batch size
+	var total int64
+	for ctx.Err() == nil {
+		deleted := s.purgeRecords(now, batchSize)
+		total += int64(deleted)
+
+		if deleted < batchSize {
+			break
+		}
+	}
+
+	return total, ctx.Err()
+}
+
+// purgeLocks deletes the reservations that expired at the given time.
+func (s *MemoryStore) purgeLocks(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, lock := range s.locks {
+		if !lock.expiredAt.After(now) {
+			delete(s.locks, id)
+		}
+	}
+}
+
+// purgeRecords deletes at most limit records that expired at the given time and returns the number of deleted records.
+func (s *MemoryStore) purgeRecords(now time.Time, limit int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := 0
+	for id, record := range s.records {
+		if deleted == limit {
+			break
+		}
+
+		if !record.ExpiredAt.After(now) {
+			delete(s.records, id)
+			deleted++
+		}
+	}
+
+	return deleted
+}
+
+// List returns a page of records that match the filter, most recent first.
+func (s *MemoryStore) List(ctx context.Context, page int, limit int, filter entity.IdempotencyCacheFilter) ([]entity.IdempotencyCache, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := make([]entity.IdempotencyCache, 0, len(s.records))
+	for _, record := range s.records {
+		if matchesFilter(record, filter) {
+			records = append(records, record)
+		}
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].CreatedAt.After(records[j].CreatedAt)
+	})
+
+	start := (page - 1) * limit
+	if start >= len(records) {
+		return nil, nil
+	}
+
+	return records[start:min(start+limit, len(records))], nil
+}
+
+// Inspect returns the record of the key, even if it has expired.
+func (s *MemoryStore) Inspect(ctx context.Context, scope string, key string) (*entity.IdempotencyCache, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[memoryID(scope, key)]
+	if !ok {
+		return nil, nil
+	}
+
+	return &record, nil
+}
+
+// Evict deletes the record and the reservation of the key.
+func (s *MemoryStore) Evict(ctx context.Context, scope string, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := memoryID(scope, key)
+	_, hasRecord := s.records[id]
+	_, hasLock := s.locks[id]
+	delete(s.records, id)
+	delete(s.locks, id)
+
+	return hasRecord || hasLock, nil
+}
+
+// matchesFilter reports whether the record matches every filter that is set.
+func matchesFilter(record entity.IdempotencyCache, filter entity.IdempotencyCacheFilter) bool {
+	if filter.Scope != "" && record.Scope != filter.Scope {
+		return false
+	}
+	if filter.CreatedFrom != nil && record.CreatedAt.Before(*filter.CreatedFrom) {
+		return false
+	}
+	if filter.CreatedTo != nil && record.CreatedAt.After(*filter.CreatedTo) {
+		return false
+	}
+	if filter.Expired != nil && record.IsExpired() != *filter.Expired {
+		return false
+	}
+	if filter.StatusCode != 0 && record.StatusCode != filter.StatusCode {
+		return false
+	}
+
+	return true
+}
+
+// memoryID returns the map key of an idempotency key within its scope.
+func memoryID(scope string, key string) string {
+	return scopedKey(scope, key)
+}
@@ -0,0 +1,352 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-idempotency-with-redis/config/database"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/entity"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/repository"
+	"github.com/yoanesber/go-idempotency-with-redis/pkg/logger"
+)
+
+const (
+	purgeAdvisoryLockID = 7305942381 // Postgres advisory lock ID that ensures only one replica purges expired keys at a time
+)
+
+// This struct defines the PostgresStore, an idempotency store that keeps records and reservations in Postgres only.
+// It contains repository fields used to access the idempotency_cache and idempotency_lock tables.
+type PostgresStore struct {
+	cacheRepo repository.IdempotencyCacheRepository
+	lockRepo  repository.IdempotencyLockRepository
+}
+
+// NewPostgresStore creates a new instance of PostgresStore with the given repositories.
+// It initializes the PostgresStore struct and returns it.
+func NewPostgresStore(cacheRepo repository.IdempotencyCacheRepository, lockRepo repository.IdempotencyLockRepository) *PostgresStore {
+	return &PostgresStore{cacheRepo: cacheRepo, lockRepo: lockRepo}
+}
+
+// Reserve reserves the key by inserting a row into the idempotency_lock table.
+// A reservation whose lease has expired, e.g. after a crash, is taken over.
+func (s *PostgresStore) Reserve(ctx context.Context, scope string, key string, lease time.Duration) (Reservation, error) {
+	db := database.GetPostgres()
+	if db == nil {
+		return Reservation{}, fmt.Errorf("database connection is nil")
+	}
+	db = db.WithContext(ctx)
+
+	token, err := newToken()
+	if err != nil {
+		return Reservation{}, err
+	}
+
+	reservation := Reservation{}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Remove an expired reservation of the key, so it can be taken over
+		existing, err := s.lockRepo.GetIdempotencyLock(tx, scope, key)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if err == nil && existing.IsExpired() {
+			if err := s.lockRepo.DeleteIdempotencyLock(tx, scope, key, existing.Token); err != nil {
+				return err
+			}
+		}
+
+		// Insert the reservation, unless another request holds it
+		now := time.Now()
+		acquired, err := s.lockRepo.CreateIdempotencyLock(tx, entity.IdempotencyLock{
+			Scope:     scope,
+			Key:       key,
+			Token:     token,
+			CreatedAt: now,
+			ExpiredAt: now.Add(lease),
+		})
+		if err != nil {
+			return err
+		}
+
+		if acquired {
+			reservation = Reservation{Token: token, Acquired: true}
+		} else {
+			reservation = Reservation{RetryAfter: time.Until(existing.ExpiredAt)}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return Reservation{}, err
+	}
+
+	return reservation, nil
+}
+
+// Get retrieves the unexpired record of the key from the database.
+func (s *PostgresStore) Get(ctx context.Context, scope string, key string) (*entity.IdempotencyCache, error) {
+	db := database.GetPostgres()
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	db = db.WithContext(ctx)
+
+	idemData, err := s.cacheRepo.GetIdempotencyCacheByKey(db, scope, key)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// An expired key is treated as never seen
+	if idemData.IsExpired() {
+		return nil, nil
+	}
+
+	return &idemData, nil
+}
+
+// Complete stores the response of the request in the database.
+// If the key was claimed by the service, the existing record is completed; otherwise a new record is created.
+func (s *PostgresStore) Complete(ctx context.Context, record entity.IdempotencyCache) (entity.IdempotencyCache, error) {
+	db := database.GetPostgres()
+	if db == nil {
+		return entity.IdempotencyCache{}, fmt.Errorf("database connection is nil")
+	}
+	db = db.WithContext(ctx)
+
+	savedIdemData := entity.IdempotencyCache{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Retrieve the existing idempotency key, if it was claimed by the service
+		existing, err := s.cacheRepo.GetIdempotencyCacheByKey(tx, record.Scope, record.Key)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		var existingPtr *entity.IdempotencyCache
+		if err == nil {
+			existingPtr = &existing
+		}
+
+		// Save the idempotency key in the database
+		savedIdemData, err = s.cacheRepo.UpdateIdempotencyCache(tx, completeRecord(existingPtr, record, time.Now()))
+		return err
+	})
+
+	if err != nil {
+		return entity.IdempotencyCache{}, err
+	}
+
+	return savedIdemData, nil
+}
+
+// Discard discards the record of the key if it has no stored response.
+func (s *PostgresStore) Discard(ctx context.Context, scope string, key string) error {
+	return s.discardIncomplete(ctx, scope, key)
+}
+
+// Release deletes the reservation held by the token and discards the record of the key if it has no stored response.
+func (s *PostgresStore) Release(ctx context.Context, scope string, key string, token string) error {
+	db := database.GetPostgres()
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	db = db.WithContext(ctx)
+
+	if err := s.discardIncomplete(ctx, scope, key); err != nil {
+		return err
+	}
+
+	return s.lockRepo.DeleteIdempotencyLock(db, scope, key, token)
+}
+
+// Purge deletes expired keys and reservations from the database in batches of the given size.
+// A Postgres advisory lock ensures that only one replica purges at a time; if another replica holds it, nothing is deleted.
+// The purge stops between batches once the context is cancelled.
+func (s *PostgresStore) Purge(ctx context.Context, batchSize int) (int64, error) {
+	db := database.GetPostgres()
+	if db == nil {
+		return 0, fmt.Errorf("database connection is nil")
+	}
+
+	if batchSize <= 0 {
+		return 0, fmt.Errorf("batch size must be a positive integer")
+	}
+
+	// Advisory locks are held by a database session, so the lock, the deletes and the unlock share one connection
+	var total int64
+	err := db.Connection(func(conn *gorm.DB) error {
+		// The connection is shared by several statements, so each of them starts from a fresh session instead of
+		// inheriting the conditions of the previous one
+		conn = conn.Session(&gorm.Session{})
+
+		var locked bool
+		if err := conn.Raw("SELECT pg_try_advisory_lock(?)", purgeAdvisoryLockID).Scan(&locked).Error; err != nil {
+			return fmt.Errorf("failed to acquire purge lock: %w", err)
+		}
+
+		// Another replica is already purging
+		if !locked {
+			return nil
+		}
+
+		defer func() {
+			if err := conn.Exec("SELECT pg_advisory_unlock(?)", purgeAdvisoryLockID).Error; err != nil {
+				logger.Error(fmt.Sprintf("Failed to release purge lock: %v", err), nil)
+			}
+		}()
+
+		// Delete reservations left behind by crashed requests
+		now := time.Now()
+		if _, err := s.lockRepo.DeleteExpiredIdempotencyLocks(conn, now); err != nil {
+			return err
+		}
+
+		// Delete expired keys until a batch comes back smaller than the batch size
+		for ctx.Err() == nil {
+			deleted, err := s.cacheRepo.DeleteExpiredIdempotencyCaches(conn, now, batchSize)
+			total += deleted
+			if err != nil {
+				return err
+			}
+
+			if deleted < int64(batchSize) {
+				break
+			}
+		}
+
+		return nil
+	})
+
+	return total, err
+}
+
+// List retrieves a page of records that match the filter from the database, most recent first.
+func (s *PostgresStore) List(ctx context.Context, page int, limit int, filter entity.IdempotencyCacheFilter) ([]entity.IdempotencyCache, error) {
+	db := database.GetPostgres()
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	return s.cacheRepo.GetAllIdempotencyCaches(db.WithContext(ctx), page, limit, filter)
+}
+
+// Inspect retrieves the record of the key from the database, even if it has expired.
+func (s *PostgresStore) Inspect(ctx context.Context, scope string, key string) (*entity.IdempotencyCache, error) {
+	db := database.GetPostgres()
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	idemData, err := s.cacheRepo.GetIdempotencyCacheByKey(db.WithContext(ctx), scope, key)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &idemData, nil
+}
+
+// Evict deletes the record and the reservation of the key from the database in one database transaction.
+func (s *PostgresStore) Evict(ctx context.Context, scope string, key string) (bool, error) {
+	db := database.GetPostgres()
+	if db == nil {
+		return false, fmt.Errorf("database connection is nil")
+	}
+	db = db.WithContext(ctx)
+
+	evicted := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		_, err := s.cacheRepo.GetIdempotencyCacheByKey(tx, scope, key)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if err == nil {
+			if err := s.cacheRepo.DeleteIdempotencyCache(tx, scope, key); err != nil {
+				return err
+			}
+			evicted = true
+		}
+
+		// The reservation is deleted whoever holds it, so a request stuck in flight no longer blocks the key
+		lock, err := s.lockRepo.GetIdempotencyLock(tx, scope, key)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if err == nil {
+			if err := s.lockRepo.DeleteIdempotencyLock(tx, scope, key, lock.Token); err != nil {
+				return err
+			}
+			evicted = true
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return false, err
+	}
+
+	return evicted, nil
+}
+
+// isReserved reports whether the key is reserved by an in-flight request, and the remaining lease of the reservation.
+func (s *PostgresStore) isReserved(ctx context.Context, scope string, key string) (bool, time.Duration, error) {
+	db := database.GetPostgres()
+	if db == nil {
+		return false, 0, fmt.Errorf("database connection is nil")
+	}
+	db = db.WithContext(ctx)
+
+	lock, err := s.lockRepo.GetIdempotencyLock(db, scope, key)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, 0, nil
+	}
+	if err != nil {
+		return false, 0, err
+	}
+
+	if lock.IsExpired() {
+		return false, 0, nil
+	}
+
+	return true, time.Until(lock.ExpiredAt), nil
+}
+
+// discardIncomplete deletes the record of the key if it has no stored response, so a retry with the key is processed again.
+// A record with a stored response is left untouched.
+func (s *PostgresStore) discardIncomplete(ctx context.Context, scope string, key string) error {
+	db := database.GetPostgres()
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	db = db.WithContext(ctx)
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		// Retrieve the idempotency key, if it was claimed by the service
+		idemData, err := s.cacheRepo.GetIdempotencyCacheByKey(tx, scope, key)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// Keep the key if the response of an earlier request has already been stored
+		if idemData.IsCompleted() {
+			return nil
+		}
+
+		return s.cacheRepo.DeleteIdempotencyCache(tx, scope, key)
+	})
+}
@@ -0,0 +1,262 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/yoanesber/go-idempotency-with-redis/internal/entity"
+	"github.com/yoanesber/go-idempotency-with-redis/pkg/logger"
+	redisutil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/redis-util"
+)
+
+const (
+	lockKeySuffix       = ":lock" // Suffix appended to the idempotency Redis key for the in-flight lock
+	postgresTokenPrefix = "pg:"   // Prefix of the token of a reservation taken in Postgres while Redis was unavailable
+)
+
+const (
+	FailClosed = "FAIL_CLOSED" // Redis errors fail the request
+	FailOver   = "FAIL_OVER"   // Redis errors fall back to Postgres-only idempotency
+)
+
+// This struct defines the RedisStore, an idempotency store that serves lookups and in-flight locks from Redis.
+// Records are persisted in Postgres through the wrapped PostgresStore, which is used as a fallback on a Redis miss,
+// and, with the FAIL_OVER policy, for lookups and reservations while Redis is unavailable.
+type RedisStore struct {
+	postgres      *PostgresStore
+	failurePolicy string
+	lastFailover  atomic.Int64 // Time of the last fall back to Postgres, in Unix nanoseconds
+}
+
+// NewRedisStore creates a new instance of RedisStore backed by the given Postgres store.
+// The failure policy decides whether Redis errors fail the request (FAIL_CLOSED) or fall back to Postgres (FAIL_OVER).
+// It initializes the RedisStore struct and returns it.
+func NewRedisStore(postgres *PostgresStore, failurePolicy string) *RedisStore {
+	if failurePolicy != FailOver {
+		failurePolicy = FailClosed
+	}
+
+	return &RedisStore{postgres: postgres, failurePolicy: failurePolicy}
+}
+
+// Reserve reserves the key with an atomic in-flight lock in Redis (SET NX with the lease as TTL).
+func (s *RedisStore) Reserve(ctx context.Context, scope string, key string, lease time.Duration) (Reservation, error) {
+	token, err := newToken()
+	if err != nil {
+		return Reservation{}, err
+	}
+
+	lockKey := GetRedisKey(scope, key) + lockKeySuffix
+	acquired, err := redisutil.SetNX(ctx, lockKey, token, lease)
+	if err != nil {
+		if s.failOver("reserve", err) {
+			return s.reservePostgres(ctx, scope, key, lease)
+		}
+		return Reservation{}, err
+	}
+
+	if !acquired {
+		// The remaining lease tells the client when to retry
+		ttl, err := redisutil.GetTTL(ctx, lockKey)
+		if err != nil {
+			ttl = 0
+		}
+
+		return Reservation{RetryAfter: ttl}, nil
+	}
+
+	// A reservation taken in Postgres while Redis was unavailable may still be held by an in-flight request
+	// It can only be held within one lease of the last fall back, so the check is skipped otherwise
+	if time.Since(time.Unix(0, s.lastFailover.Load())) < lease {
+		held, retryAfter, err := s.postgres.isReserved(ctx, scope, key)
+		if err != nil || held {
+			if _, releaseErr := redisutil.DeleteKeyIfValue(ctx, lockKey, token); releaseErr != nil {
+				logger.Error(fmt.Sprintf("Failed to release idempotency lock: %v", releaseErr), nil)
+			}
+			if err != nil {
+				return Reservation{}, err
+			}
+
+			return Reservation{RetryAfter: retryAfter}, nil
+		}
+	}
+
+	return Reservation{Token: token, Acquired: true}, nil
+}
+
+// Get retrieves the unexpired record of the key, reading through Redis to Postgres.
+// On a Redis miss (e.g., after a flush or eviction), the durable record in Postgres is used and,
+// if it holds a response, Redis is rehydrated with the remaining lifetime of the key.
+func (s *RedisStore) Get(ctx context.Context, scope string, key string) (*entity.IdempotencyCache, error) {
+	// Check Redis first, as it holds the most recently used idempotency keys
+	redisKey := GetRedisKey(scope, key)
+	cachedData, err := redisutil.GetJSON[entity.IdempotencyCache](ctx, redisKey)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		if s.failOver("get", err) {
+			return s.postgres.Get(ctx, scope, key)
+		}
+		return nil, err
+	}
+
+	if cachedData != nil {
+		return cachedData, nil
+	}
+
+	// Fall back to the durable record in Postgres
+	idemData, err := s.postgres.Get(ctx, scope, key)
+	if err != nil || idemData == nil {
+		return nil, err
+	}
+
+	// Rehydrate Redis with the remaining lifetime, so subsequent lookups do not hit the database
+	// Keys without a response are not rehydrated, as they are still being processed
+	if idemData.IsCompleted() {
+		if err := redisutil.SetJSON(ctx, redisKey, idemData, time.Until(idemData.ExpiredAt)); err != nil {
+			logger.Error(fmt.Sprintf("Failed to rehydrate idempotency key in Redis: %v", err), nil)
+		}
+	}
+
+	return idemData, nil
+}
+
+// Complete stores the response of the request in Postgres and then in Redis.
+// The Redis entry expires at the same time as the record in Postgres.
+func (s *RedisStore) Complete(ctx context.Context, record entity.IdempotencyCache) (entity.IdempotencyCache, error) {
+	savedIdemData, err := s.postgres.Complete(ctx, record)
+	if err != nil {
+		return entity.IdempotencyCache{}, err
+	}
+
+	// Store the idempotency key in Redis with the remaining lifetime, so both stores expire together
+	ttl := time.Until(savedIdemData.ExpiredAt)
+	if ttl <= 0 {
+		return savedIdemData, nil
+	}
+
+	if err := redisutil.SetJSON(ctx, GetRedisKey(record.Scope, record.Key), savedIdemData, ttl); err != nil {
+		// The response is stored in Postgres, so retries are replayed from there until Redis is back
+		if s.failOver("complete", err) {
+			return savedIdemData, nil
+		}
+		return entity.IdempotencyCache{}, fmt.Errorf("failed to set idempotency key in Redis: %w", err)
+	}
+
+	return savedIdemData, nil
+}
+
+// Discard discards the record of the key in Postgres if it has no stored response.
+// Records without a response are never written to Redis, so there is nothing to discard there.
+func (s *RedisStore) Discard(ctx context.Context, scope string, key string) error {
+	return s.postgres.discardIncomplete(ctx, scope, key)
+}
+
+// Release discards the record of the key if it has no stored response, and then releases the in-flight lock
+// if it is still held by the token. A lock whose lease has expired and been taken over by another request is left untouched.
+func (s *RedisStore) Release(ctx context.Context, scope string, key string, token string) error {
+	// A reservation taken in Postgres while Redis was unavailable is released in Postgres
+	if strings.HasPrefix(token, postgresTokenPrefix) {
+		return s.postgres.Release(ctx, scope, key, strings.TrimPrefix(token, postgresTokenPrefix))
+	}
+
+	if err := s.postgres.discardIncomplete(ctx, scope, key); err != nil {
+		return err
+	}
+
+	if _, err := redisutil.DeleteKeyIfValue(ctx, GetRedisKey(scope, key)+lockKeySuffix, token); err != nil {
+		// The lock expires on its own at the end of its lease
+		if s.failOver("release", err) {
+			return nil
+		}
+		return fmt.Errorf("failed to release idempotency lock: %w", err)
+	}
+
+	return nil
+}
+
+// Purge deletes expired keys from Postgres. Redis entries expire on their own at the same time.
+func (s *RedisStore) Purge(ctx context.Context, batchSize int) (int64, error) {
+	return s.postgres.Purge(ctx, batchSize)
+}
+
+// List retrieves a page of records from Postgres, which holds every record, including those evicted from Redis.
+func (s *RedisStore) List(ctx context.Context, page int, limit int, filter entity.IdempotencyCacheFilter) ([]entity.IdempotencyCache, error) {
+	return s.postgres.List(ctx, page, limit, filter)
+}
+
+// Inspect retrieves the record of the key from Postgres, which holds every record, including those evicted from Redis.
+func (s *RedisStore) Inspect(ctx context.Context, scope string, key string) (*entity.IdempotencyCache, error) {
+	return s.postgres.Inspect(ctx, scope, key)
+}
+
+// Evict deletes the record and the in-flight lock of the key from Redis, and then the record and reservation from Postgres.
+// Redis is cleared first, so a failure never leaves a stale response in Redis without its record in Postgres,
+// and the eviction can simply be retried. Redis errors fail the eviction whatever the failure policy, as a response left
+// in Redis would still be replayed.
+func (s *RedisStore) Evict(ctx context.Context, scope string, key string) (bool, error) {
+	redisKey := GetRedisKey(scope, key)
+
+	evictedRecord, err := redisutil.DeleteKey(ctx, redisKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete idempotency key from Redis: %w", err)
+	}
+
+	evictedLock, err := redisutil.DeleteKey(ctx, redisKey+lockKeySuffix)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete idempotency lock from Redis: %w", err)
+	}
+
+	evicted, err := s.postgres.Evict(ctx, scope, key)
+	if err != nil {
+		return false, err
+	}
+
+	// A lookup between both deletes may have rehydrated the record into Redis, so it is deleted once more
+	// The record is already gone from Postgres, so a failure here is only logged
+	if _, err := redisutil.DeleteKey(ctx, redisKey); err != nil {
+		logger.Warn(fmt.Sprintf("Failed to delete rehydrated idempotency key %s in scope %s from Redis: %v", key, scope, err), nil)
+	}
+
+	return evicted || evictedRecord || evictedLock, nil
+}
+
+// reservePostgres reserves the key in Postgres while Redis is unavailable.
+// The token is marked, so the reservation is released in Postgres as well.
+func (s *RedisStore) reservePostgres(ctx context.Context, scope string, key string, lease time.Duration) (Reservation, error) {
+	reservation, err := s.postgres.Reserve(ctx, scope, key, lease)
+	if err != nil {
+		return Reservation{}, err
+	}
+
+	if reservation.Acquired {
+		reservation.Token = postgresTokenPrefix + reservation.Token
+	}
+
+	return reservation, nil
+}
+
+// failOver reports whether the Redis error is handled by falling back to Postgres, which is the case with the FAIL_OVER policy.
+// The fall back is logged, except while the circuit breaker is open, as the breaker already logged that Redis is unavailable.
+func (s *RedisStore) failOver(operation string, err error) bool {
+	if s.failurePolicy != FailOver {
+		return false
+	}
+
+	s.lastFailover.Store(time.Now().UnixNano())
+	if !redisutil.IsCircuitOpen(err) {
+		logger.Warn(fmt.Sprintf("Redis unavailable, idempotency %s falls back to Postgres: %v", operation, err), nil)
+	}
+
+	return true
+}
+
+// GetRedisKey returns the Redis key of an idempotency key within its scope, e.g. idempotency_cache:6:global:<key>.
+func GetRedisKey(scope string, key string) string {
+	return os.Getenv("IDEMPOTENCY_PREFIX") + scopedKey(scope, key)
+}
@@ -0,0 +1,74 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/yoanesber/go-idempotency-with-redis/internal/store"
+	"github.com/yoanesber/go-idempotency-with-redis/pkg/logger"
+	envutil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/env-util"
+)
+
+const (
+	defaultPurgeIntervalMinutes = 10   // Default interval between purges, used when IDEMPOTENCY_PURGE_INTERVAL_MINUTES is not set
+	defaultPurgeBatchSize       = 1000 // Default number of keys deleted per batch, used when IDEMPOTENCY_PURGE_BATCH_SIZE is not set
+)
+
+// This struct defines the IdempotencyJanitor, a background worker that periodically purges expired idempotency keys.
+// It contains a store field of type IdempotencyStore which is used to delete the expired keys.
+type IdempotencyJanitor struct {
+	store     store.IdempotencyStore
+	interval  time.Duration
+	batchSize int
+	done      chan struct{}
+}
+
+// NewIdempotencyJanitor creates a new instance of IdempotencyJanitor with the given idempotency store.
+// The purge interval and batch size are read from the IDEMPOTENCY_PURGE_INTERVAL_MINUTES and IDEMPOTENCY_PURGE_BATCH_SIZE environment variables.
+func NewIdempotencyJanitor(idemStore store.IdempotencyStore) *IdempotencyJanitor {
+	return &IdempotencyJanitor{
+		store:     idemStore,
+		interval:  time.Duration(envutil.GetPositiveInt("IDEMPOTENCY_PURGE_INTERVAL_MINUTES", defaultPurgeIntervalMinutes)) * time.Minute,
+		batchSize: envutil.GetPositiveInt("IDEMPOTENCY_PURGE_BATCH_SIZE", defaultPurgeBatchSize),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start runs the janitor in the background until the given context is cancelled.
+// Expired keys are purged once at startup and then on every interval.
+func (j *IdempotencyJanitor) Start(ctx context.Context) {
+	go func() {
+		defer close(j.done)
+
+		ticker := time.NewTicker(j.interval)
+		defer ticker.Stop()
+
+		for {
+			j.purge(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Wait blocks until the janitor has stopped after its context was cancelled.
+// It is used during graceful shutdown so the database connection is not closed while a purge is running.
+func (j *IdempotencyJanitor) Wait() {
+	<-j.done
+}
+
+// purge deletes the expired idempotency keys and logs how many were removed.
+func (j *IdempotencyJanitor) purge(ctx context.Context) {
+	deleted, err := j.store.Purge(ctx, j.batchSize)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to purge expired idempotency keys after removing %d: %v", deleted, err), nil)
+		return
+	}
+
+	logger.Info(fmt.Sprintf("Purged %d expired idempotency keys", deleted), nil)
+}
@@ -0,0 +1,175 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-idempotency-with-redis/config/database"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/entity"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/publisher"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/repository"
+	"github.com/yoanesber/go-idempotency-with-redis/pkg/logger"
+	envutil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/env-util"
+)
+
+const (
+	defaultOutboxPollIntervalMs = 1000 // Default interval between polls of the outbox, used when OUTBOX_POLL_INTERVAL_MS is not set
+	defaultOutboxBatchSize      = 100  // Default number of messages delivered per batch, used when OUTBOX_BATCH_SIZE is not set
+	defaultOutboxMaxAttempts    = 10   // Default number of delivery attempts before a message is marked as failed, used when OUTBOX_MAX_ATTEMPTS is not set
+
+	outboxBaseRetryDelay = time.Second     // Delay before the first retry of a failed delivery; doubled on every further attempt
+	outboxMaxRetryDelay  = 5 * time.Minute // Upper bound of the delay between two delivery attempts
+)
+
+// This struct defines the OutboxRelay, a background worker that delivers the pending messages of the outbox to a publisher.
+// Delivery is at least once: a message is marked as delivered only after it was published, so it may be published again
+// if the relay stops in between. Failed deliveries are retried with exponential backoff.
+type OutboxRelay struct {
+	repo        repository.OutboxMessageRepository
+	publisher   publisher.Publisher
+	interval    time.Duration
+	batchSize   int
+	maxAttempts int
+	done        chan struct{}
+}
+
+// NewOutboxRelay creates a new instance of OutboxRelay with the given repository and publisher.
+// The poll interval, batch size and maximum number of attempts are read from the OUTBOX_POLL_INTERVAL_MS,
+// OUTBOX_BATCH_SIZE and OUTBOX_MAX_ATTEMPTS environment variables.
+func NewOutboxRelay(repo repository.OutboxMessageRepository, pub publisher.Publisher) *OutboxRelay {
+	return &OutboxRelay{
+		repo:        repo,
+		publisher:   pub,
+		interval:    time.Duration(envutil.GetPositiveInt("OUTBOX_POLL_INTERVAL_MS", defaultOutboxPollIntervalMs)) * time.Millisecond,
+		batchSize:   envutil.GetPositiveInt("OUTBOX_BATCH_SIZE", defaultOutboxBatchSize),
+		maxAttempts: envutil.GetPositiveInt("OUTBOX_MAX_ATTEMPTS", defaultOutboxMaxAttempts),
+		done:        make(chan struct{}),
+	}
+}
+
+// Start runs the relay in the background until the given context is cancelled.
+// The outbox is polled on every interval; a full batch is followed by the next one without waiting.
+func (r *OutboxRelay) Start(ctx context.Context) {
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			claimed, err := r.deliverBatch(ctx)
+			if err != nil {
+				logger.Error(fmt.Sprintf("Failed to relay outbox messages: %v", err), nil)
+			}
+
+			if err == nil && claimed == r.batchSize && ctx.Err() == nil {
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Wait blocks until the relay has stopped after its context was cancelled.
+// It is used during graceful shutdown so the database and Redis connections are not closed while a batch is delivered.
+func (r *OutboxRelay) Wait() {
+	<-r.done
+}
+
+// deliverBatch claims a batch of due messages, publishes each of them and records the outcome.
+// It returns the number of claimed messages.
+func (r *OutboxRelay) deliverBatch(ctx context.Context) (int, error) {
+	db := database.GetPostgres()
+	if db == nil {
+		return 0, fmt.Errorf("database connection is nil")
+	}
+
+	// The outcome of the messages published so far is still committed when the context is cancelled mid-batch
+	db = db.WithContext(context.WithoutCancel(ctx))
+
+	claimed, delivered := 0, 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		messages, err := r.repo.ClaimPendingOutboxMessages(tx, time.Now(), r.batchSize)
+		if err != nil {
+			return err
+		}
+		claimed = len(messages)
+
+		for _, m := range messages {
+			// Stop publishing on shutdown; the remaining messages are delivered after the restart
+			if ctx.Err() != nil {
+				break
+			}
+
+			if r.deliver(ctx, &m) {
+				delivered++
+			}
+
+			if err := r.repo.UpdateOutboxMessage(tx, m); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if delivered > 0 {
+		logger.Info(fmt.Sprintf("Relayed %d of %d outbox messages", delivered, claimed), nil)
+	}
+
+	return claimed, err
+}
+
+// deliver publishes the message and updates its status, attempts and next attempt time.
+// It returns whether the message was published.
+func (r *OutboxRelay) deliver(ctx context.Context, m *entity.OutboxMessage) bool {
+	err := r.publisher.Publish(ctx, publisher.Message{
+		ID:                    m.ID,
+		Topic:                 m.Topic,
+		AggregateID:           m.AggregateID,
+		IdempotencyCacheScope: m.IdempotencyCacheScope,
+		IdempotencyCacheKey:   m.IdempotencyCacheKey,
+		Payload:               m.Payload,
+		CreatedAt:             m.CreatedAt,
+	})
+
+	now := time.Now()
+	m.Attempts++
+
+	if err == nil {
+		m.Status = entity.OutboxStatusDelivered
+		m.LastError = ""
+		m.DeliveredAt = &now
+		return true
+	}
+
+	m.LastError = err.Error()
+	if m.Attempts >= r.maxAttempts {
+		m.Status = entity.OutboxStatusFailed
+		logger.Error(fmt.Sprintf("Giving up on outbox message %s after %d attempts: %v", m.ID, m.Attempts, err), nil)
+		return false
+	}
+
+	m.NextAttemptAt = now.Add(outboxRetryDelay(m.Attempts))
+	logger.Warn(fmt.Sprintf("Failed to deliver outbox message %s (attempt %d of %d): %v", m.ID, m.Attempts, r.maxAttempts, err), nil)
+	return false
+}
+
+// outboxRetryDelay returns the delay before the next delivery attempt of a message that failed the given number of times.
+// The delay doubles on every attempt, up to outboxMaxRetryDelay.
+func outboxRetryDelay(attempts int) time.Duration {
+	delay := outboxBaseRetryDelay
+	for i := 1; i < attempts && delay < outboxMaxRetryDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, outboxMaxRetryDelay)
+}
@@ -2,16 +2,25 @@ package metacontext
 
 import (
 	"context"
+	"time"
 )
 
 // This struct defines the IdemCompetencyMeta struct
 //
 //	It can be used to store metadata about the idem competency information
 type IdemCompetencyMeta struct {
+	Scope           string
 	Key             string
+	RequestMethod   string
+	RequestPath     string
+	QueryHash       string
+	HeadersHash     string
 	BodyHash        string
 	ResponsePayload string
+	ResponseHeaders map[string]string
 	StatusCode      int
+	ExpiredAt       time.Time
+	LockExpiresAt   time.Time // Time the in-flight lock of the request expires; work past it may run concurrently with a retry
 }
 
 // This struct defines the IdemCompetencyMetaKeyType struct
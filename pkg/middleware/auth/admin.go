@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	httputil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/http-util"
+)
+
+const (
+	bearerPrefix = "Bearer " // Prefix of the Authorization header value that carries the admin token
+)
+
+/**
+* AdminAuth is a middleware function that restricts a route to administrators.
+* It expects the admin token from the ADMIN_API_TOKEN environment variable in the `Authorization: Bearer <token>` header.
+* The token is compared in constant time, so it cannot be guessed by measuring response times.
+* If ADMIN_API_TOKEN is not set, the admin routes are disabled and every request is rejected with 403 Forbidden.
+ */
+func AdminAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminToken := os.Getenv("ADMIN_API_TOKEN")
+		if adminToken == "" {
+			httputil.Forbidden(c, "Forbidden", "Admin API is disabled")
+			c.Abort()
+			return
+		}
+
+		// Get the admin token from the Authorization header
+		authHeader := c.GetHeader("Authorization")
+		if !strings.HasPrefix(authHeader, bearerPrefix) {
+			httputil.Unauthorized(c, "Unauthorized", "Admin token is required in the Authorization header")
+			c.Abort()
+			return
+		}
+
+		token := strings.TrimPrefix(authHeader, bearerPrefix)
+		if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			httputil.Unauthorized(c, "Unauthorized", "Invalid admin token")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,53 @@
+package idempotency
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+)
+
+const (
+	defaultRetryableStatusCodes = "401,403,408,409,423,425,429" // Client errors that are not stored, used when IDEMPOTENCY_RETRYABLE_STATUS_CODES is not set
+)
+
+// CacheDecision determines what happens to the idempotency key once the handler has responded.
+type CacheDecision int
+
+const (
+	CacheRelease CacheDecision = iota // Do not store the response and release the key, so a retry is processed again
+	CacheStore                        // Store the response, so every retry with the key replays it until the key expires
+)
+
+// CachePolicy decides, from the status code of the response, whether the outcome of a request is stored.
+type CachePolicy func(status int) CacheDecision
+
+// NewCachePolicy creates the default cache policy, following the semantics of payment APIs such as Stripe:
+//   - 2xx and 3xx responses are stored.
+//   - 4xx responses are business rejections and are stored, except the status codes listed in
+//     IDEMPOTENCY_RETRYABLE_STATUS_CODES (authentication, timeouts, conflicts and rate limits by default),
+//     which may succeed when retried.
+//   - 5xx responses are never stored, and the key is released so the request can be retried.
+func NewCachePolicy() CachePolicy {
+	codes := os.Getenv("IDEMPOTENCY_RETRYABLE_STATUS_CODES")
+	if codes == "" {
+		codes = defaultRetryableStatusCodes
+	}
+
+	retryable := make(map[int]bool)
+	for _, code := range splitList(codes) {
+		if status, err := strconv.Atoi(code); err == nil {
+			retryable[status] = true
+		}
+	}
+
+	return func(status int) CacheDecision {
+		switch {
+		case status < http.StatusOK || status >= http.StatusInternalServerError:
+			return CacheRelease
+		case retryable[status]:
+			return CacheRelease
+		default:
+			return CacheStore
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package idempotency
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yoanesber/go-idempotency-with-redis/internal/entity"
+	hashutil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/hash-util"
+)
+
+// fingerprint identifies a request so that a reused idempotency key can be matched against the original request.
+// Each part is kept separately so that a mismatch can be reported precisely.
+type fingerprint struct {
+	Method      string
+	Path        string
+	QueryHash   string
+	HeadersHash string
+	BodyHash    string
+}
+
+// newFingerprint builds the fingerprint of the current request.
+// It covers the method, the resolved request path (including path parameters such as the transaction ID),
+// the canonicalized query string, the allow-listed headers and the request body.
+func newFingerprint(c *gin.Context, body []byte, cfg *config) (fingerprint, error) {
+	queryHash, err := hashOptional([]byte(c.Request.URL.Query().Encode()))
+	if err != nil {
+		return fingerprint{}, fmt.Errorf("failed to hash query string: %w", err)
+	}
+
+	headersHash, err := hashOptional([]byte(canonicalHeaders(c.Request.Header, cfg.fingerprintHeaders)))
+	if err != nil {
+		return fingerprint{}, fmt.Errorf("failed to hash request headers: %w", err)
+	}
+
+	bodyHash, err := hashBody(body, cfg.bodyHashMode)
+	if err != nil {
+		return fingerprint{}, fmt.Errorf("failed to hash request body: %w", err)
+	}
+
+	return fingerprint{
+		Method:      c.Request.Method,
+		Path:        c.Request.URL.Path,
+		QueryHash:   queryHash,
+		HeadersHash: headersHash,
+		BodyHash:    bodyHash,
+	}, nil
+}
+
+// mismatches compares the fingerprint with the one stored for the original request.
+// It returns one entry per differing part, in a format suitable for an error response.
+// Only the differing part is named: the original request may belong to another caller, so none of its values are echoed.
+func (f fingerprint) mismatches(cachedData *entity.IdempotencyCache) []map[string]string {
+	var diffs []map[string]string
+
+	if f.Method != cachedData.RequestMethod {
+		diffs = append(diffs, map[string]string{
+			"field":   "method",
+			"message": "method does not match the original request",
+		})
+	}
+	if f.Path != cachedData.RequestPath {
+		diffs = append(diffs, map[string]string{
+			"field":   "path",
+			"message": "path does not match the original request",
+		})
+	}
+	if f.QueryHash != cachedData.QueryHash {
+		diffs = append(diffs, map[string]string{
+			"field":   "query",
+			"message": "query string does not match the original request",
+		})
+	}
+	if f.HeadersHash != cachedData.HeadersHash {
+		diffs = append(diffs, map[string]string{
+			"field":   "headers",
+			"message": "fingerprinted headers do not match the original request",
+		})
+	}
+	if f.BodyHash != cachedData.BodyHash {
+		diffs = append(diffs, map[string]string{
+			"field":   "body",
+			"message": "request body does not match the original request",
+		})
+	}
+
+	return diffs
+}
+
+// canonicalHeaders renders the allow-listed headers in a stable order, one "name:value" pair per line.
+// Header names are compared case-insensitively and missing headers are rendered with an empty value.
+func canonicalHeaders(h http.Header, names []string) string {
+	canonical := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		canonical = append(canonical, name+":"+strings.Join(h.Values(name), ","))
+	}
+	sort.Strings(canonical)
+
+	return strings.Join(canonical, "\n")
+}
+
+// hashBody hashes the request body according to the given mode.
+// In canonical mode, a body that is not valid JSON is hashed as raw bytes and left for the handler to reject.
+func hashBody(body []byte, mode BodyHashMode) (string, error) {
+	if mode == BodyHashCanonical && len(body) > 0 {
+		if hash, err := hashutil.HashCanonicalJSON(body); err == nil {
+			return hash, nil
+		}
+	}
+
+	return hashOptional(body)
+}
+
+// hashOptional hashes the given bytes using SHA-256.
+// An empty input yields an empty hash, so requests without a query string, headers or body can still be fingerprinted.
+func hashOptional(b []byte) (string, error) {
+	if len(b) == 0 {
+		return "", nil
+	}
+
+	return hashutil.Hash256Bytes(b)
+}
@@ -2,29 +2,45 @@ package idempotency
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/go-redis/redis/v8"
 
 	"github.com/yoanesber/go-idempotency-with-redis/internal/entity"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/store"
 	metacontext "github.com/yoanesber/go-idempotency-with-redis/pkg/context-data/meta-context"
-	hashutil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/hash-util"
+	"github.com/yoanesber/go-idempotency-with-redis/pkg/logger"
 	httputil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/http-util"
 	redisutil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/redis-util"
 )
 
+const (
+	replayedHeader = "Idempotent-Replayed" // Response header that marks a replayed response
+)
+
 /**
 * Enforce is a middleware function that implements idempotency for HTTP requests.
 * It checks if the request has an idempotency key and whether the request has already been processed.
-* If the request has already been processed, it returns the cached response.
-* If the request has not been processed, it injects the idempotency metadata into the context
-* and allows the request to proceed to the handler.
+* Routes whose clients send no idempotency key header, such as webhooks, can derive the key from the request with WithKey.
+* If the request has already been processed, it replays the original status code, headers and body,
+* provided the request fingerprint (method, route, query string, selected headers and body) matches the original request.
+* Records and reservations are kept in the given idempotency store (Redis, Postgres or in-memory).
+* If the request has not been processed, it reserves the key with an in-flight lock so that concurrent
+* requests with the same key are rejected (or wait for the first result), injects the idempotency metadata
+* into the context and allows the request to proceed to the handler.
+* Once the handler completes, the response is recorded and, depending on the cache policy, either stored or the key is released,
+* so the middleware can be attached to any route without changes to the service layer. Services that need the idempotency record to be written in their own
+* database transaction can opt in through IdempotencyCacheService.ClaimIdempotencyCache.
  */
-func Enforce() gin.HandlerFunc {
+func Enforce(idemStore store.IdempotencyStore, opts ...Option) gin.HandlerFunc {
+	cfg := newConfig(opts...)
+
 	return func(c *gin.Context) {
 		// Read the environment variables
 		idemEnabled := os.Getenv("IDEMPOTENCY_ENABLED")
@@ -43,85 +59,277 @@ func Enforce() gin.HandlerFunc {
 			return
 		}
 
-		// Ensure that the request method is POST, PUT, or DELETE
-		if c.Request.Method != "POST" && c.Request.Method != "PUT" && c.Request.Method != "DELETE" {
-			httputil.MethodNotAllowed(c, "Method Not Allowed", "Idempotency middleware only supports POST, PUT, or DELETE methods")
-			c.Abort()
+		// Let safe methods through if the route mixes them with unsafe methods
+		if cfg.safeMethodPassthrough && isSafeMethod(c.Request.Method) {
+			c.Next()
 			return
 		}
 
-		// Get the idempotency key from the request header
-		// The idempotency key is expected to be provided in the request header
-		idemKey := c.GetHeader(idemKeyHdr)
-		if idemKey == "" {
-			httputil.BadRequest(c, "Bad Request", fmt.Sprintf("Idempotency key header '%s' is required", idemKeyHdr))
+		// Ensure that the request method is POST, PUT, PATCH, or DELETE
+		if !isUnsafeMethod(c.Request.Method) {
+			httputil.MethodNotAllowed(c, "Method Not Allowed", "Idempotency middleware only supports POST, PUT, PATCH, or DELETE methods")
 			c.Abort()
 			return
 		}
 
+		// Get the idempotency key from the request header
+		// The idempotency key is expected to be provided in the request header, unless the route takes it from the request
+		var idemKey string
+		if cfg.keyExtractor == nil {
+			idemKey = c.GetHeader(idemKeyHdr)
+			if idemKey == "" && cfg.optionalKey {
+				// Without a key, the request is processed without idempotency
+				c.Next()
+				return
+			}
+
+			if idemKey == "" {
+				httputil.BadRequest(c, "Bad Request", fmt.Sprintf("Idempotency key header '%s' is required", idemKeyHdr))
+				c.Abort()
+				return
+			}
+
+			// Validate the idempotency key against the key policy, so an invalid key never reaches the database
+			if err := cfg.keyPolicy.Validate(idemKey); err != nil {
+				httputil.BadRequest(c, "Invalid idempotency key", err.Error())
+				c.Abort()
+				return
+			}
+
+			// Keys that only differ in letter case refer to the same request, e.g. a lowercase ULID
+			idemKey = cfg.keyPolicy.Normalize(idemKey)
+		}
+
 		// Read the request body
-		bodyBytes, err := c.GetRawData()
-		if err != nil {
-			httputil.InternalServerError(c, "Internal Server Error", "Failed to read request body")
-			c.Abort()
-			return
+		// Requests without a body, such as a PATCH with query parameters only, are fingerprinted with an empty body
+		var bodyBytes []byte
+		if c.Request.Body != nil {
+			b, err := c.GetRawData()
+			if err != nil {
+				httputil.InternalServerError(c, "Internal Server Error", "Failed to read request body")
+				c.Abort()
+				return
+			}
+			bodyBytes = b
 		}
 
 		// Restore the request body so it can be read again later in the handler
 		// This is necessary because reading the body consumes it, and we need it for further processing
 		c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 
-		// Hash the request body to create a unique identifier
-		bodyHash, err := hashutil.Hash256Bytes(bodyBytes)
+		// Derive the idempotency key from the request, for routes that do not use the idempotency key header
+		if cfg.keyExtractor != nil {
+			key, err := resolveKey(c, bodyBytes, cfg)
+			if err != nil {
+				httputil.BadRequest(c, "Bad Request", err.Error())
+				c.Abort()
+				return
+			}
+			idemKey = key
+		}
+
+		// Fingerprint the request to detect a reused key with a different request
+		fp, err := newFingerprint(c, bodyBytes, cfg)
 		if err != nil {
-			httputil.InternalServerError(c, "Internal Server Error", "Failed to hash request body")
+			httputil.InternalServerError(c, "Internal Server Error", err.Error())
 			c.Abort()
 			return
 		}
 
-		// Check if the request has already been processed
-		redisKey := idemPrefix + idemKey
-		cachedData, err := redisutil.GetJSON[entity.IdempotencyCache](redisKey)
-		if err != nil && err != redis.Nil {
-			httputil.InternalServerError(c, "Internal Server Error", err.Error())
+		// Determine the scope of the idempotency key, so keys from different clients never collide
+		scope, err := resolveScope(c, bodyBytes, cfg.scopeExtractor)
+		if err != nil {
+			httputil.BadRequest(c, "Bad Request", err.Error())
 			c.Abort()
 			return
 		}
 
-		if cachedData != nil {
-			// If idempotency key exists in Redis with different body hash, return conflict error
-			if cachedData.BodyHash != bodyHash {
-				httputil.Conflict(c, "Conflict", "Request with the same Idempotency-Key but different body has already been processed")
-				c.Abort()
-				return
-			}
+		// Lookups and reservations are bound to the request context, so they stop when the client disconnects
+		// The reservation is released and the response stored with cancellation detached, so a disconnect never leaves the key locked
+		reqCtx := c.Request.Context()
+		storeCtx := context.WithoutCancel(reqCtx)
+
+		// Check if the request has already been processed
+		// With the Redis store, the durable record in the database is used on a Redis miss
+		cachedData, err := idemStore.Get(reqCtx, scope, idemKey)
+		if err != nil {
+			respondStoreError(c, err)
+			return
+		}
+
+		if cachedData.IsCompleted() {
+			replayCachedResponse(c, cachedData, fp)
+			return
+		}
 
-			var respPayload any
-			if cachedData.ResponsePayload != "" {
-				if err := json.Unmarshal([]byte(cachedData.ResponsePayload), &respPayload); err != nil {
-					httputil.InternalServerError(c, "Internal Server Error", "Failed to unmarshal cached response payload")
-					c.Abort()
+		// Reserve the idempotency key before processing the request
+		// This prevents concurrent requests with the same key from being processed at the same time
+		leaseStart := time.Now()
+		reservation, err := idemStore.Reserve(reqCtx, scope, idemKey, getLockTTL())
+		if err != nil {
+			respondStoreError(c, err)
+			return
+		}
+
+		// If another request holds the reservation, optionally wait for its result
+		if !reservation.Acquired {
+			if waitTimeout := getLockWaitTimeout(); waitTimeout > 0 {
+				cachedData, reservation, err = waitForResult(reqCtx, idemStore, scope, idemKey, waitTimeout)
+				if err != nil {
+					respondStoreError(c, err)
+					return
+				}
+
+				if cachedData != nil {
+					replayCachedResponse(c, cachedData, fp)
 					return
 				}
 			}
+		}
 
-			// If the request has already been processed, return the cached response
-			httputil.Success(c, "Request already processed", respPayload)
+		if !reservation.Acquired {
+			c.Header("Retry-After", strconv.Itoa(retryAfterSeconds(reservation.RetryAfter)))
+			httputil.Conflict(c, "Request in progress", "A request with the same Idempotency-Key is currently being processed")
 			c.Abort()
 			return
 		}
 
+		// Release the reservation once the handler completes, including when it panics
+		// A key without a stored response is discarded, including a claim made by the service, so a retry is processed again
+		defer func() {
+			if err := idemStore.Release(storeCtx, scope, idemKey, reservation.Token); err != nil {
+				logger.Error(fmt.Sprintf("Failed to release idempotency key: %v", err), nil)
+			}
+		}()
+
+		// Check again in case the request was completed between the first lookup and acquiring the reservation
+		cachedData, err = idemStore.Get(reqCtx, scope, idemKey)
+		if err != nil {
+			respondStoreError(c, err)
+			return
+		}
+
+		if cachedData.IsCompleted() {
+			replayCachedResponse(c, cachedData, fp)
+			return
+		}
+
+		// A record without a response cannot belong to a request in flight, as this request holds the reservation
+		// It was left behind by a crashed request or a failed release, so it is discarded and the request is processed
+		if cachedData != nil {
+			if err := idemStore.Discard(reqCtx, scope, idemKey); err != nil {
+				respondStoreError(c, err)
+				return
+			}
+		}
+
 		// Inject the idempotency metadata into the context
 		// This metadata will be used later to create or update the idempotency key in the database
 		meta := metacontext.IdemCompetencyMeta{
-			Key:      idemKey,
-			BodyHash: bodyHash,
+			Scope:         scope,
+			Key:           idemKey,
+			RequestMethod: fp.Method,
+			RequestPath:   fp.Path,
+			QueryHash:     fp.QueryHash,
+			HeadersHash:   fp.HeadersHash,
+			BodyHash:      fp.BodyHash,
+			ExpiredAt:     cfg.ttlPolicy.ExpiresAt(time.Now()),
+			LockExpiresAt: leaseStart.Add(getLockTTL()),
 		}
-		ctx := metacontext.InjectIdemCompetencyMeta(c.Request.Context(), meta)
+		ctx := metacontext.InjectIdemCompetencyMeta(reqCtx, meta)
 
 		// Set the new request context with idempotency metadata
 		c.Request = c.Request.WithContext(ctx)
 
+		// Wrap the response writer to capture the response written by the handler
+		recorder := newResponseRecorder(c.Writer)
+		c.Writer = recorder
+
 		c.Next()
+
+		// Store the response according to the cache policy of the route
+		// A response that is not stored leaves the key without a response, so it is released above
+		status := recorder.Status()
+		if cfg.cachePolicy(status) != CacheStore {
+			return
+		}
+
+		// Store the captured status code, headers and raw body in the idempotency store
+		meta.StatusCode = status
+		meta.ResponseHeaders = recorder.Headers()
+		meta.ResponsePayload = string(recorder.Body())
+
+		if _, err := idemStore.Complete(storeCtx, newRecord(meta)); err != nil {
+			logger.Error(fmt.Sprintf("Failed to store idempotency response: %v", err), nil)
+		}
+	}
+}
+
+// isSafeMethod reports whether the HTTP method is safe, i.e. it does not change state on the server.
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// isUnsafeMethod reports whether the HTTP method changes state on the server, so it can be made idempotent.
+func isUnsafeMethod(method string) bool {
+	return method == http.MethodPost || method == http.MethodPut || method == http.MethodPatch || method == http.MethodDelete
+}
+
+// respondStoreError aborts the request after the idempotency store failed.
+// A Redis timeout is reported as a gateway timeout, so it is not mistaken for a missing key or a server bug,
+// and an open Redis circuit breaker as a temporary outage.
+func respondStoreError(c *gin.Context, err error) {
+	if redisutil.IsCircuitOpen(err) {
+		c.Header("Retry-After", strconv.Itoa(retryAfterSeconds(0)))
+		httputil.ServiceUnavailable(c, "Service Unavailable", err.Error())
+	} else if redisutil.IsTimeout(err) {
+		httputil.GatewayTimeout(c, "Gateway Timeout", err.Error())
+	} else {
+		httputil.InternalServerError(c, "Internal Server Error", err.Error())
+	}
+	c.Abort()
+}
+
+// replayCachedResponse writes the cached response of an already processed request byte-for-byte,
+// including the original status code and headers, and marks it with the Idempotent-Replayed header.
+// If the cached fingerprint does not match the current request, it responds with an unprocessable entity error instead.
+func replayCachedResponse(c *gin.Context, cachedData *entity.IdempotencyCache, fp fingerprint) {
+	// If idempotency key exists in Redis with a different request, explain which part differs
+	if diffs := fp.mismatches(cachedData); len(diffs) > 0 {
+		httputil.UnprocessableEntityMap(c, "Request with the same Idempotency-Key but a different request has already been processed", diffs)
+		c.Abort()
+		return
+	}
+
+	// If the original request has not stored its response yet, it is still being processed
+	if !cachedData.IsCompleted() {
+		httputil.Conflict(c, "Request in progress", "A request with the same Idempotency-Key is currently being processed")
+		c.Abort()
+		return
+	}
+
+	// If the request has already been processed, return the cached response
+	for name, value := range cachedData.ResponseHeaders {
+		c.Header(name, value)
+	}
+	c.Header(replayedHeader, "true")
+	c.Data(cachedData.StatusCode, cachedData.ResponseHeaders["Content-Type"], []byte(cachedData.ResponsePayload))
+	c.Abort()
+}
+
+// newRecord creates the idempotency record of a processed request from the request fingerprint and captured response in the metadata.
+func newRecord(meta metacontext.IdemCompetencyMeta) entity.IdempotencyCache {
+	return entity.IdempotencyCache{
+		Scope:           meta.Scope,
+		Key:             meta.Key,
+		RequestMethod:   meta.RequestMethod,
+		RequestPath:     meta.RequestPath,
+		QueryHash:       meta.QueryHash,
+		HeadersHash:     meta.HeadersHash,
+		BodyHash:        meta.BodyHash,
+		StatusCode:      meta.StatusCode,
+		ResponseHeaders: meta.ResponseHeaders,
+		ResponsePayload: meta.ResponsePayload,
+		ExpiredAt:       meta.ExpiredAt,
 	}
 }
@@ -0,0 +1,57 @@
+package idempotency
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// KeyExtractor determines the identifier of the current request that the idempotency key is derived from,
+// for routes whose clients do not send an idempotency key header, such as webhooks.
+// It receives the raw request body, since the body has already been consumed by the middleware.
+// An empty identifier is rejected by the middleware.
+type KeyExtractor func(c *gin.Context, body []byte) (string, error)
+
+// KeyFromHeader derives idempotency keys from the value of the given request header (e.g., a webhook delivery ID).
+func KeyFromHeader(name string) KeyExtractor {
+	return func(c *gin.Context, body []byte) (string, error) {
+		return strings.TrimSpace(c.GetHeader(name)), nil
+	}
+}
+
+// KeyFromBodyField derives idempotency keys from a top-level string field of the JSON request body (e.g., eventId).
+func KeyFromBodyField(field string) KeyExtractor {
+	return func(c *gin.Context, body []byte) (string, error) {
+		if len(body) == 0 {
+			return "", nil
+		}
+
+		var fields map[string]any
+		if err := json.Unmarshal(body, &fields); err != nil {
+			return "", fmt.Errorf("request body must be a JSON object to determine the idempotency key")
+		}
+
+		value, ok := fields[field].(string)
+		if !ok {
+			return "", nil
+		}
+
+		return strings.TrimSpace(value), nil
+	}
+}
+
+// resolveKey determines the idempotency key of the current request with the key extractor of the route.
+func resolveKey(c *gin.Context, body []byte, cfg *config) (string, error) {
+	id, err := cfg.keyExtractor(c, body)
+	if err != nil {
+		return "", err
+	}
+
+	if id == "" {
+		return "", fmt.Errorf("idempotency key could not be determined from the request")
+	}
+
+	return cfg.keyPolicy.Derive(id), nil
+}
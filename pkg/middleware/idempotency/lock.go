@@ -0,0 +1,73 @@
+package idempotency
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/yoanesber/go-idempotency-with-redis/internal/entity"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/store"
+	envutil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/env-util"
+)
+
+const (
+	lockPollInterval      = 100 * time.Millisecond // Interval used to poll for the result while waiting on another request
+	defaultLockTTLSeconds = 30                     // Default lease of the in-flight lock, used when IDEMPOTENCY_LOCK_TTL_SECONDS is not set
+)
+
+// getLockTTL returns the lease of the in-flight lock.
+// The lease bounds how long a crashed request can block retries with the same key.
+func getLockTTL() time.Duration {
+	return time.Duration(envutil.GetNonNegativeInt("IDEMPOTENCY_LOCK_TTL_SECONDS", defaultLockTTLSeconds)) * time.Second
+}
+
+// getLockWaitTimeout returns how long a concurrent request waits for the first result.
+// A zero value means the concurrent request is rejected immediately.
+func getLockWaitTimeout() time.Duration {
+	return time.Duration(envutil.GetNonNegativeInt("IDEMPOTENCY_LOCK_WAIT_SECONDS", 0)) * time.Second
+}
+
+// retryAfterSeconds returns the number of seconds a client should wait before retrying,
+// based on the remaining lease of the reservation held by another request.
+func retryAfterSeconds(lease time.Duration) int {
+	if lease <= 0 {
+		return 1
+	}
+
+	return int(math.Ceil(lease.Seconds()))
+}
+
+// waitForResult polls the store until the request holding the reservation stores its result,
+// or the reservation is released and can be taken over, or the timeout elapses.
+// It returns the stored result if one became available, and otherwise the outcome of the last reservation attempt.
+// Waiting stops early once the context is cancelled, e.g. when the client disconnects.
+func waitForResult(ctx context.Context, idemStore store.IdempotencyStore, scope string, key string, timeout time.Duration) (*entity.IdempotencyCache, store.Reservation, error) {
+	reservation := store.Reservation{}
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return nil, store.Reservation{}, ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+
+		cachedData, err := idemStore.Get(ctx, scope, key)
+		if err != nil {
+			return nil, store.Reservation{}, err
+		}
+		if cachedData.IsCompleted() {
+			return cachedData, store.Reservation{}, nil
+		}
+
+		// Take over the reservation if the first request finished without storing a result
+		reservation, err = idemStore.Reserve(ctx, scope, key, getLockTTL())
+		if err != nil {
+			return nil, store.Reservation{}, err
+		}
+		if reservation.Acquired {
+			return nil, reservation, nil
+		}
+	}
+
+	return nil, reservation, nil
+}
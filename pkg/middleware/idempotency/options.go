@@ -0,0 +1,133 @@
+package idempotency
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	keyutil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/key-util"
+)
+
+// BodyHashMode determines how the request body is hashed for the request fingerprint.
+type BodyHashMode string
+
+const (
+	BodyHashCanonical BodyHashMode = "CANONICAL" // Hash the canonical form of a JSON body, so reordered keys or whitespace still match
+	BodyHashRaw       BodyHashMode = "RAW"       // Hash the raw bytes of the body
+)
+
+// Option configures the idempotency middleware for a single route.
+type Option func(*config)
+
+// config holds the per-route settings of the idempotency middleware.
+type config struct {
+	fingerprintHeaders    []string
+	bodyHashMode          BodyHashMode
+	scopeExtractor        ScopeExtractor
+	ttlPolicy             TTLPolicy
+	cachePolicy           CachePolicy
+	safeMethodPassthrough bool
+	optionalKey           bool
+	keyPolicy             keyutil.KeyPolicy
+	keyExtractor          KeyExtractor
+}
+
+// newConfig creates the middleware configuration from the environment and applies the given options.
+func newConfig(opts ...Option) *config {
+	cfg := &config{
+		fingerprintHeaders: splitList(os.Getenv("IDEMPOTENCY_FINGERPRINT_HEADERS")),
+		bodyHashMode:       BodyHashCanonical,
+		scopeExtractor:     defaultScopeExtractor(),
+		ttlPolicy:          NewTTLPolicy(),
+		cachePolicy:        NewCachePolicy(),
+		keyPolicy:          keyutil.NewKeyPolicy(),
+	}
+
+	if os.Getenv("IDEMPOTENCY_BODY_HASH_MODE") == string(BodyHashRaw) {
+		cfg.bodyHashMode = BodyHashRaw
+	}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return cfg
+}
+
+// WithFingerprintHeaders sets the request headers that are included in the request fingerprint.
+// It overrides the IDEMPOTENCY_FINGERPRINT_HEADERS environment variable for the route.
+func WithFingerprintHeaders(headers ...string) Option {
+	return func(cfg *config) {
+		cfg.fingerprintHeaders = headers
+	}
+}
+
+// WithBodyHashMode sets how the request body is hashed for the route.
+// It overrides the IDEMPOTENCY_BODY_HASH_MODE environment variable, which defaults to CANONICAL.
+func WithBodyHashMode(mode BodyHashMode) Option {
+	return func(cfg *config) {
+		cfg.bodyHashMode = mode
+	}
+}
+
+// WithScope sets how the idempotency keys of the route are namespaced.
+// It overrides the IDEMPOTENCY_SCOPE_HEADER environment variable for the route.
+func WithScope(extractor ScopeExtractor) Option {
+	return func(cfg *config) {
+		cfg.scopeExtractor = extractor
+	}
+}
+
+// WithTTL overrides the lifetime of idempotency keys for the route.
+// Routes without an override use the default lifetime from IDEMPOTENCY_TTL_HOURS.
+func WithTTL(ttl time.Duration) Option {
+	return func(cfg *config) {
+		cfg.ttlPolicy.Override = ttl
+	}
+}
+
+// WithCachePolicy sets which outcomes of the route are stored for replay.
+// It overrides the default policy, which stores 2xx and 4xx responses and never stores 5xx responses.
+func WithCachePolicy(policy CachePolicy) Option {
+	return func(cfg *config) {
+		cfg.cachePolicy = policy
+	}
+}
+
+// WithSafeMethodPassthrough lets GET, HEAD and OPTIONS requests pass through without idempotency,
+// so the middleware can be mounted on a route group that mixes safe and unsafe methods.
+// Without it, safe methods are rejected with 405 Method Not Allowed.
+func WithSafeMethodPassthrough() Option {
+	return func(cfg *config) {
+		cfg.safeMethodPassthrough = true
+	}
+}
+
+// WithOptionalKey processes requests without an idempotency key header without idempotency,
+// instead of rejecting them with 400 Bad Request.
+func WithOptionalKey() Option {
+	return func(cfg *config) {
+		cfg.optionalKey = true
+	}
+}
+
+// WithKey takes the idempotency key of the route from the request, instead of the idempotency key header,
+// e.g. the event ID of a webhook. The extracted identifier is mapped to a key that follows the key policy with KeyPolicy.Derive,
+// so identifiers in any format can be used.
+func WithKey(extractor KeyExtractor) Option {
+	return func(cfg *config) {
+		cfg.keyExtractor = extractor
+	}
+}
+
+// splitList splits a comma-separated list and drops empty entries.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
@@ -0,0 +1,64 @@
+package idempotency
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// replayHeaders lists the response headers that are stored and replayed for idempotent requests.
+// Headers that depend on the transport (e.g., Content-Length, Content-Encoding) are intentionally excluded.
+var replayHeaders = []string{
+	"Content-Type",
+	"Location",
+	"ETag",
+	"Last-Modified",
+	"Cache-Control",
+}
+
+// responseRecorder wraps gin.ResponseWriter to capture the response body written by the handler.
+// The response is still written to the underlying writer, so the client receives it unchanged.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+// newResponseRecorder creates a new responseRecorder wrapping the given writer.
+func newResponseRecorder(w gin.ResponseWriter) *responseRecorder {
+	return &responseRecorder{ResponseWriter: w, body: &bytes.Buffer{}}
+}
+
+// Write captures the bytes written to the response and forwards them to the underlying writer.
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// WriteString captures the string written to the response and forwards it to the underlying writer.
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Body returns the captured response body.
+func (w *responseRecorder) Body() []byte {
+	return w.body.Bytes()
+}
+
+// Headers returns the captured response headers that are eligible for replay.
+func (w *responseRecorder) Headers() map[string]string {
+	return selectReplayHeaders(w.Header())
+}
+
+// selectReplayHeaders returns the headers from h that are listed in replayHeaders.
+func selectReplayHeaders(h http.Header) map[string]string {
+	headers := make(map[string]string)
+	for _, name := range replayHeaders {
+		if value := h.Get(name); value != "" {
+			headers[name] = value
+		}
+	}
+
+	return headers
+}
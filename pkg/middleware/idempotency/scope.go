@@ -0,0 +1,112 @@
+package idempotency
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	hashutil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/hash-util"
+)
+
+const (
+	DefaultScope = "global" // Scope used when no scope extractor is configured for the route
+
+	maxScopeLength = 255 // Maximum length of a scope, matching the idempotency_cache.scope column
+)
+
+// ScopeExtractor determines the namespace of an idempotency key for the current request,
+// so that keys chosen by different clients never collide.
+// It receives the raw request body, since the body has already been consumed by the middleware.
+// An empty scope is rejected by the middleware.
+type ScopeExtractor func(c *gin.Context, body []byte) (string, error)
+
+// ScopeFromHeader scopes idempotency keys by the value of the given request header (e.g., a client or tenant ID).
+func ScopeFromHeader(name string) ScopeExtractor {
+	return func(c *gin.Context, body []byte) (string, error) {
+		return strings.TrimSpace(c.GetHeader(name)), nil
+	}
+}
+
+// ScopeFromAPIKey scopes idempotency keys by the API key sent in the given request header.
+// The API key is hashed, so the secret itself is never stored in Redis or the database.
+func ScopeFromAPIKey(name string) ScopeExtractor {
+	return func(c *gin.Context, body []byte) (string, error) {
+		apiKey := strings.TrimSpace(c.GetHeader(name))
+		if apiKey == "" {
+			return "", nil
+		}
+
+		return hashutil.Hash256String(apiKey)
+	}
+}
+
+// ScopeFromBodyField scopes idempotency keys by a top-level string field of the JSON request body (e.g., consumerId).
+func ScopeFromBodyField(field string) ScopeExtractor {
+	return func(c *gin.Context, body []byte) (string, error) {
+		if len(body) == 0 {
+			return "", nil
+		}
+
+		var fields map[string]any
+		if err := json.Unmarshal(body, &fields); err != nil {
+			return "", fmt.Errorf("request body must be a JSON object to determine the idempotency scope")
+		}
+
+		value, ok := fields[field].(string)
+		if !ok {
+			return "", nil
+		}
+
+		return strings.TrimSpace(value), nil
+	}
+}
+
+// FixedScope scopes the idempotency keys of the route by the given scope, e.g. the name of a webhook source,
+// so they never collide with keys of other routes.
+func FixedScope(scope string) ScopeExtractor {
+	return func(c *gin.Context, body []byte) (string, error) {
+		return scope, nil
+	}
+}
+
+// ScopeFromContext scopes idempotency keys by the authenticated principal stored in the gin context
+// under the given key, typically set by an authentication middleware that runs before this one.
+func ScopeFromContext(key string) ScopeExtractor {
+	return func(c *gin.Context, body []byte) (string, error) {
+		return c.GetString(key), nil
+	}
+}
+
+// defaultScopeExtractor returns the scope extractor configured by the IDEMPOTENCY_SCOPE_HEADER environment variable.
+// It returns nil if the variable is not set, in which case all keys share the global scope.
+func defaultScopeExtractor() ScopeExtractor {
+	if header := os.Getenv("IDEMPOTENCY_SCOPE_HEADER"); header != "" {
+		return ScopeFromHeader(header)
+	}
+
+	return nil
+}
+
+// resolveScope determines the scope of the idempotency key for the current request.
+func resolveScope(c *gin.Context, body []byte, extractor ScopeExtractor) (string, error) {
+	if extractor == nil {
+		return DefaultScope, nil
+	}
+
+	scope, err := extractor(c, body)
+	if err != nil {
+		return "", err
+	}
+
+	if scope == "" {
+		return "", fmt.Errorf("idempotency scope could not be determined from the request")
+	}
+	if len(scope) > maxScopeLength {
+		return "", fmt.Errorf("idempotency scope must be at most %d characters", maxScopeLength)
+	}
+
+	return scope, nil
+}
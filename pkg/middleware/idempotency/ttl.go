@@ -0,0 +1,48 @@
+package idempotency
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/yoanesber/go-idempotency-with-redis/pkg/logger"
+)
+
+const (
+	defaultTTLHours = 24 // Default lifetime of idempotency keys, used when IDEMPOTENCY_TTL_HOURS is not set
+)
+
+// TTLPolicy determines how long idempotency keys are kept.
+// The expiration time is computed once, when the request is first processed, and stored with the key.
+// Both Redis and the database expire the key at that time, and later updates never extend it.
+type TTLPolicy struct {
+	Default  time.Duration // Lifetime of keys on routes without an override, read from IDEMPOTENCY_TTL_HOURS
+	Override time.Duration // Lifetime of keys on a specific route, set with WithTTL
+}
+
+// NewTTLPolicy creates the TTL policy from the IDEMPOTENCY_TTL_HOURS environment variable.
+// It falls back to the default lifetime if the variable is not set or is not a positive integer.
+func NewTTLPolicy() TTLPolicy {
+	hours, err := strconv.Atoi(os.Getenv("IDEMPOTENCY_TTL_HOURS"))
+	if err != nil || hours <= 0 {
+		logger.Warn(fmt.Sprintf("Invalid or missing IDEMPOTENCY_TTL_HOURS, using default of %d hours", defaultTTLHours), nil)
+		hours = defaultTTLHours
+	}
+
+	return TTLPolicy{Default: time.Duration(hours) * time.Hour}
+}
+
+// TTL returns the lifetime of idempotency keys, taking the per-route override into account.
+func (p TTLPolicy) TTL() time.Duration {
+	if p.Override > 0 {
+		return p.Override
+	}
+
+	return p.Default
+}
+
+// ExpiresAt returns the expiration time of an idempotency key first processed at the given time.
+func (p TTLPolicy) ExpiresAt(now time.Time) time.Time {
+	return now.Add(p.TTL())
+}
@@ -0,0 +1,102 @@
+package webhook
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	envutil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/env-util"
+	hashutil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/hash-util"
+	httputil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/http-util"
+)
+
+const (
+	TimestampHeader = "Webhook-Timestamp" // Header that carries the time the webhook was sent, in Unix seconds
+	SignatureHeader = "Webhook-Signature" // Header that carries the signature of the webhook, as sha256=<hex>
+
+	signaturePrefix         = "sha256="
+	defaultToleranceSeconds = 300 // Default maximum age of a webhook, used when WEBHOOK_TOLERANCE_SECONDS is not set
+)
+
+/**
+* VerifySignature is a middleware function that authenticates webhooks sent by the transaction processor.
+* The processor signs `<timestamp>.<raw body>` with HMAC-SHA256, using the shared secret from the WEBHOOK_SECRET environment variable,
+* and sends the timestamp in the Webhook-Timestamp header and the signature in the Webhook-Signature header (sha256=<hex>).
+* Webhooks with an invalid signature, or sent more than WEBHOOK_TOLERANCE_SECONDS ago (or in the future), are rejected with 401 Unauthorized,
+* so a captured webhook cannot be replayed after the tolerance, when its event ID may no longer be deduplicated.
+* If WEBHOOK_SECRET is not set, webhooks are disabled and every request is rejected with 403 Forbidden.
+ */
+func VerifySignature() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		secret := os.Getenv("WEBHOOK_SECRET")
+		if secret == "" {
+			httputil.Forbidden(c, "Forbidden", "Webhooks are disabled")
+			c.Abort()
+			return
+		}
+
+		// Check that the webhook was sent within the tolerance
+		timestamp := c.GetHeader(TimestampHeader)
+		sentAt, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			httputil.Unauthorized(c, "Unauthorized", "Webhook timestamp is missing or invalid")
+			c.Abort()
+			return
+		}
+
+		if math.Abs(float64(time.Now().Unix()-sentAt)) > getTolerance().Seconds() {
+			httputil.Unauthorized(c, "Unauthorized", "Webhook timestamp is outside the tolerance")
+			c.Abort()
+			return
+		}
+
+		signature := c.GetHeader(SignatureHeader)
+		if !strings.HasPrefix(signature, signaturePrefix) {
+			httputil.Unauthorized(c, "Unauthorized", "Webhook signature is missing or invalid")
+			c.Abort()
+			return
+		}
+
+		// Read the raw body, which is signed as sent
+		body, err := c.GetRawData()
+		if err != nil {
+			httputil.InternalServerError(c, "Internal Server Error", "Failed to read request body")
+			c.Abort()
+			return
+		}
+
+		// Restore the request body so it can be read again by the next middleware and the handler
+		c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+
+		signed := append([]byte(timestamp+"."), body...)
+		if !hashutil.VerifyHmacSHA256([]byte(secret), signed, strings.TrimPrefix(signature, signaturePrefix)) {
+			httputil.Unauthorized(c, "Unauthorized", "Webhook signature is missing or invalid")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// getTolerance returns the maximum age of a webhook from the WEBHOOK_TOLERANCE_SECONDS environment variable.
+func getTolerance() time.Duration {
+	return time.Duration(envutil.GetPositiveInt("WEBHOOK_TOLERANCE_SECONDS", defaultToleranceSeconds)) * time.Second
+}
+
+// Sign returns the Webhook-Signature header value of a webhook with the given timestamp and raw body.
+// It is used by clients and tests that send signed webhooks.
+func Sign(secret string, timestamp string, body []byte) (string, error) {
+	signature, err := hashutil.HmacSHA256([]byte(secret), append([]byte(timestamp+"."), body...))
+	if err != nil {
+		return "", err
+	}
+
+	return signaturePrefix + signature, nil
+}
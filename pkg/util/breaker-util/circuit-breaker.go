@@ -0,0 +1,149 @@
+package breaker_util
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/yoanesber/go-idempotency-with-redis/pkg/logger"
+)
+
+const (
+	StateClosed   = "CLOSED"    // Calls go through; consecutive failures are counted
+	StateOpen     = "OPEN"      // Calls are rejected until the open timeout elapses
+	StateHalfOpen = "HALF_OPEN" // A single probe call goes through to check whether the dependency has recovered
+)
+
+// ErrOpen is returned by Allow while the circuit is open, so the caller fails fast instead of calling a dead dependency.
+var ErrOpen = errors.New("circuit breaker is open")
+
+// CircuitBreaker stops calls to a failing dependency after a number of consecutive failures.
+// Once the open timeout elapses, a single probe call is let through; the circuit closes if it succeeds and opens again if it fails.
+// State changes are written to the log.
+type CircuitBreaker struct {
+	mu               sync.Mutex
+	name             string
+	failureThreshold int
+	openTimeout      time.Duration
+	state            string
+	failures         int
+	openedAt         time.Time
+	probing          bool
+}
+
+// NewCircuitBreaker creates a new closed circuit breaker for the named dependency.
+// The circuit opens after failureThreshold consecutive failures and stays open for openTimeout.
+func NewCircuitBreaker(name string, failureThreshold int, openTimeout time.Duration) *CircuitBreaker {
+	if failureThreshold < 1 {
+		failureThreshold = 1
+	}
+
+	return &CircuitBreaker{
+		name:             name,
+		failureThreshold: failureThreshold,
+		openTimeout:      openTimeout,
+		state:            StateClosed,
+	}
+}
+
+// Allow reports whether a call may go through. It returns ErrOpen while the circuit is open,
+// and while a probe call is already in flight in the half-open state.
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if time.Since(b.openedAt) < b.openTimeout {
+			return ErrOpen
+		}
+
+		// The open timeout has elapsed, so let a probe call through
+		b.setState(StateHalfOpen)
+		b.probing = true
+		return nil
+	case StateHalfOpen:
+		if b.probing {
+			return ErrOpen
+		}
+
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// Record records the outcome of a call that was allowed through.
+// A failure of the probe call, or the last of failureThreshold consecutive failures, opens the circuit; a successful probe closes it.
+func (b *CircuitBreaker) Record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateHalfOpen {
+		b.probing = false
+	}
+
+	// A success of a call started before the circuit opened does not close it; only the probe call does
+	if !failed {
+		b.failures = 0
+		if b.state == StateHalfOpen {
+			b.setState(StateClosed)
+		}
+		return
+	}
+
+	b.failures++
+	if b.state == StateHalfOpen || (b.state == StateClosed && b.failures >= b.failureThreshold) {
+		b.openedAt = time.Now()
+		b.setState(StateOpen)
+	}
+}
+
+// Release ends a call that was allowed through without recording its outcome, e.g. a call cancelled by its caller,
+// which says nothing about the health of the dependency. It frees the probe slot in the half-open state, so the next call
+// probes again, and leaves the state and the count of consecutive failures unchanged.
+func (b *CircuitBreaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateHalfOpen {
+		b.probing = false
+	}
+}
+
+// State returns the current state of the circuit: CLOSED, OPEN or HALF_OPEN.
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// An open circuit whose timeout has elapsed lets the next call through as a probe
+	if b.state == StateOpen && time.Since(b.openedAt) >= b.openTimeout {
+		return StateHalfOpen
+	}
+
+	return b.state
+}
+
+// setState changes the state of the circuit and logs the transition. The caller must hold the lock.
+func (b *CircuitBreaker) setState(state string) {
+	previous := b.state
+	b.state = state
+
+	fields := log.Fields{
+		"breaker":  b.name,
+		"from":     previous,
+		"to":       state,
+		"failures": b.failures,
+	}
+
+	if state == StateOpen {
+		logger.Warn(fmt.Sprintf("Circuit breaker %s opened after %d consecutive failures", b.name, b.failures), fields)
+		return
+	}
+
+	logger.Info(fmt.Sprintf("Circuit breaker %s is %s", b.name, state), fields)
+}
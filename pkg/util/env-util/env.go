@@ -0,0 +1,28 @@
+package env_util
+
+import (
+	"os"
+	"strconv"
+)
+
+// GetPositiveInt reads a positive integer from the environment variable with the given name.
+// It returns the default value if the variable is not set or is not a positive integer.
+func GetPositiveInt(name string, def int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value <= 0 {
+		return def
+	}
+
+	return value
+}
+
+// GetNonNegativeInt reads a non-negative integer from the environment variable with the given name.
+// It returns the default value if the variable is not set or is not a non-negative integer.
+func GetNonNegativeInt(name string, def int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value < 0 {
+		return def
+	}
+
+	return value
+}
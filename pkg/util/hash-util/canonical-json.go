@@ -0,0 +1,122 @@
+package hash_util
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+)
+
+const (
+	canonicalNumberPrecision = 256 // Precision in bits used to normalize JSON numbers
+)
+
+// CanonicalJSON normalizes a JSON document so that semantically equal documents produce identical bytes.
+// Object keys are sorted, insignificant whitespace is removed and numbers are normalized (e.g., 1.0, 1e0 and 1 become 1).
+// It returns an error if the input is not a single valid JSON value.
+func CanonicalJSON(b []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	// Ensure there is no trailing data after the JSON value
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("invalid JSON: unexpected data after top-level value")
+	}
+
+	var buf bytes.Buffer
+	if err := writeCanonical(&buf, v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// HashCanonicalJSON canonicalizes a JSON document and hashes it using SHA-256.
+// It returns the hexadecimal representation of the hash.
+func HashCanonicalJSON(b []byte) (string, error) {
+	canonical, err := CanonicalJSON(b)
+	if err != nil {
+		return "", err
+	}
+
+	return Hash256Bytes(canonical)
+}
+
+// writeCanonical writes the canonical representation of a decoded JSON value to the buffer.
+func writeCanonical(buf *bytes.Buffer, v any) error {
+	switch val := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonical(buf, k); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := writeCanonical(buf, val[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+
+	case []any:
+		buf.WriteByte('[')
+		for i, item := range val {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonical(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+
+	case json.Number:
+		num, err := normalizeNumber(val)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(num)
+
+	default:
+		// Strings, booleans and null are encoded as-is
+		b, err := json.Marshal(val)
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+	}
+
+	return nil
+}
+
+// normalizeNumber converts a JSON number into its shortest decimal representation.
+// Equal numbers written differently (e.g., 10, 10.0, 1e1) yield the same string.
+func normalizeNumber(n json.Number) (string, error) {
+	f, _, err := big.ParseFloat(n.String(), 10, canonicalNumberPrecision, big.ToNearestEven)
+	if err != nil {
+		return "", fmt.Errorf("invalid JSON number %s: %w", n, err)
+	}
+
+	// Treat negative zero as zero
+	if f.Sign() == 0 {
+		return "0", nil
+	}
+
+	return f.Text('g', -1), nil
+}
@@ -0,0 +1,36 @@
+package hash_util
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// HmacSHA256 signs a byte slice with HMAC-SHA256 and returns the hexadecimal representation of the signature.
+func HmacSHA256(secret []byte, b []byte) (string, error) {
+	if len(secret) == 0 {
+		return "", fmt.Errorf("secret cannot be empty")
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(b)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// VerifyHmacSHA256 reports whether the hexadecimal signature is the HMAC-SHA256 signature of the byte slice.
+// The signatures are compared in constant time, so a valid signature cannot be guessed by measuring response times.
+func VerifyHmacSHA256(secret []byte, b []byte, signature string) bool {
+	if len(secret) == 0 {
+		return false
+	}
+
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(b)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -165,6 +165,21 @@ func Conflict(c *gin.Context, message string, err string) {
 	})
 }
 
+// UnprocessableEntity sends a 422 Unprocessable Entity response.
+// It is typically used when the request is well-formed but cannot be processed due to semantic errors.
+func UnprocessableEntity(c *gin.Context, message string, err string) {
+	logger.Error(err, nil)
+
+	c.JSON(http.StatusUnprocessableEntity, HttpResponse{
+		Message:   message,
+		Error:     err,
+		Path:      c.Request.URL.Path,
+		Status:    http.StatusUnprocessableEntity,
+		Data:      nil,
+		Timestamp: time.Now(),
+	})
+}
+
 // TooManyRequests sends a 429 Too Many Requests response.
 // It is typically used when the user has sent too many requests in a given amount of time.
 func TooManyRequests(c *gin.Context, message string, err string) {
@@ -180,6 +195,51 @@ func TooManyRequests(c *gin.Context, message string, err string) {
 	})
 }
 
+// BadGateway sends a 502 Bad Gateway response.
+// It is typically used when a downstream service, such as the transaction processor, fails or cannot be reached.
+func BadGateway(c *gin.Context, message string, err string) {
+	logger.Error(err, nil)
+
+	c.JSON(http.StatusBadGateway, HttpResponse{
+		Message:   message,
+		Error:     err,
+		Path:      c.Request.URL.Path,
+		Status:    http.StatusBadGateway,
+		Data:      nil,
+		Timestamp: time.Now(),
+	})
+}
+
+// ServiceUnavailable sends a 503 Service Unavailable response.
+// It is typically used when a backing service, such as Redis, is temporarily unavailable.
+func ServiceUnavailable(c *gin.Context, message string, err string) {
+	logger.Error(err, nil)
+
+	c.JSON(http.StatusServiceUnavailable, HttpResponse{
+		Message:   message,
+		Error:     err,
+		Path:      c.Request.URL.Path,
+		Status:    http.StatusServiceUnavailable,
+		Data:      nil,
+		Timestamp: time.Now(),
+	})
+}
+
+// GatewayTimeout sends a 504 Gateway Timeout response.
+// It is typically used when a backing service, such as Redis, does not respond in time.
+func GatewayTimeout(c *gin.Context, message string, err string) {
+	logger.Error(err, nil)
+
+	c.JSON(http.StatusGatewayTimeout, HttpResponse{
+		Message:   message,
+		Error:     err,
+		Path:      c.Request.URL.Path,
+		Status:    http.StatusGatewayTimeout,
+		Data:      nil,
+		Timestamp: time.Now(),
+	})
+}
+
 // NoContent sends a 204 No Content response.
 // It is typically used when the server successfully processes the request but does not need to return any content.
 func NoContent(c *gin.Context, message string, err string) {
@@ -300,6 +360,19 @@ func ConflictMap(c *gin.Context, message string, err []map[string]string) {
 	})
 }
 
+func UnprocessableEntityMap(c *gin.Context, message string, err []map[string]string) {
+	logger.Error("Unprocessable Entity Map Error", nil)
+
+	c.JSON(http.StatusUnprocessableEntity, HttpResponse{
+		Message:   message,
+		Error:     err,
+		Path:      c.Request.URL.Path,
+		Status:    http.StatusUnprocessableEntity,
+		Data:      nil,
+		Timestamp: time.Now(),
+	})
+}
+
 func TooManyRequestsMap(c *gin.Context, message string, err []map[string]string) {
 	logger.Error("Too Many Requests Map Error", nil)
 
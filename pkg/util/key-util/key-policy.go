@@ -0,0 +1,164 @@
+package key_util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	envutil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/env-util"
+)
+
+// KeyFormat is the format that idempotency keys must follow.
+type KeyFormat string
+
+const (
+	KeyFormatUUID4  KeyFormat = "UUID4"  // RFC 9562 UUID version 4, e.g. 06f14f72-dfba-49ca-aa4e-d85b532ca0b7
+	KeyFormatUUID7  KeyFormat = "UUID7"  // RFC 9562 UUID version 7, e.g. 0190163d-8694-739b-aea5-966c26f8ad91
+	KeyFormatULID   KeyFormat = "ULID"   // Crockford base32 ULID, e.g. 01ARZ3NDEKTSV4RRFFQ69G5FAV
+	KeyFormatOpaque KeyFormat = "OPAQUE" // Any printable ASCII string up to the maximum length
+
+	defaultKeyMaxLength = 255 // Default maximum length of idempotency keys, used when IDEMPOTENCY_KEY_MAX_LENGTH is not set
+
+	crockfordBase32 = "0123456789ABCDEFGHJKMNPQRSTVWXYZ" // Alphabet of ULIDs
+)
+
+var (
+	uuid4Pattern  = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-4[0-9a-fA-F]{3}-[89abAB][0-9a-fA-F]{3}-[0-9a-fA-F]{12}$`)
+	uuid7Pattern  = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-7[0-9a-fA-F]{3}-[89abAB][0-9a-fA-F]{3}-[0-9a-fA-F]{12}$`)
+	ulidPattern   = regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Za-hjkmnp-tv-z]{25}$`)
+	opaquePattern = regexp.MustCompile(`^[\x21-\x7E]+$`)
+)
+
+// KeyPolicy determines which idempotency keys are accepted.
+// It applies to the whole application, as the Postgres column type of the keys follows the format.
+type KeyPolicy struct {
+	Format    KeyFormat
+	MaxLength int
+}
+
+// NewKeyPolicy creates the key policy from the IDEMPOTENCY_KEY_FORMAT and IDEMPOTENCY_KEY_MAX_LENGTH environment variables.
+// It defaults to UUIDv4 keys of at most 255 characters.
+func NewKeyPolicy() KeyPolicy {
+	policy := KeyPolicy{Format: KeyFormatUUID4, MaxLength: defaultKeyMaxLength}
+
+	switch format := KeyFormat(os.Getenv("IDEMPOTENCY_KEY_FORMAT")); format {
+	case KeyFormatUUID7, KeyFormatULID, KeyFormatOpaque:
+		policy.Format = format
+	}
+
+	policy.MaxLength = envutil.GetPositiveInt("IDEMPOTENCY_KEY_MAX_LENGTH", policy.MaxLength)
+
+	return policy
+}
+
+// Validate checks the idempotency key against the policy.
+// The returned error explains what is wrong with the key and can be returned to the client.
+func (p KeyPolicy) Validate(key string) error {
+	if len(key) > p.MaxLength {
+		return fmt.Errorf("idempotency key must be at most %d characters", p.MaxLength)
+	}
+
+	switch p.Format {
+	case KeyFormatUUID7:
+		if !uuid7Pattern.MatchString(key) {
+			return fmt.Errorf("idempotency key must be a UUIDv7")
+		}
+	case KeyFormatULID:
+		if !ulidPattern.MatchString(key) {
+			return fmt.Errorf("idempotency key must be a ULID")
+		}
+	case KeyFormatOpaque:
+		if !opaquePattern.MatchString(key) {
+			return fmt.Errorf("idempotency key must only contain printable ASCII characters")
+		}
+	default:
+		if !uuid4Pattern.MatchString(key) {
+			return fmt.Errorf("idempotency key must be a UUIDv4")
+		}
+	}
+
+	return nil
+}
+
+// Normalize returns the canonical form of a key that passed Validate, so equivalent spellings of a key share one record.
+// ULIDs are uppercased and UUIDs lowercased, as both are case-insensitive; opaque keys are case-sensitive and kept as is.
+func (p KeyPolicy) Normalize(key string) string {
+	switch p.Format {
+	case KeyFormatULID:
+		return strings.ToUpper(key)
+	case KeyFormatOpaque:
+		return key
+	default:
+		return strings.ToLower(key)
+	}
+}
+
+// ColumnType returns the Postgres column type that stores keys accepted by the policy.
+func (p KeyPolicy) ColumnType() string {
+	switch p.Format {
+	case KeyFormatULID:
+		return "char(26)"
+	case KeyFormatOpaque:
+		return fmt.Sprintf("varchar(%d)", p.MaxLength)
+	default:
+		return "uuid"
+	}
+}
+
+// Derive maps an arbitrary identifier, such as the event ID of a webhook, to a key that follows the policy.
+// The same identifier always maps to the same key. UUID and ULID keys are built from the SHA-256 hash of the identifier
+// (a derived UUIDv7 or ULID does not carry a meaningful timestamp); an opaque identifier is kept as is if it is valid,
+// and hashed otherwise.
+func (p KeyPolicy) Derive(id string) string {
+	sum := sha256.Sum256([]byte(id))
+
+	switch p.Format {
+	case KeyFormatUUID7:
+		return formatUUID(sum[:16], 0x70)
+	case KeyFormatULID:
+		return formatULID(sum[:16])
+	case KeyFormatOpaque:
+		if p.Validate(id) == nil {
+			return id
+		}
+
+		key := hex.EncodeToString(sum[:])
+		if len(key) > p.MaxLength {
+			key = key[:p.MaxLength]
+		}
+		return key
+	default:
+		return formatUUID(sum[:16], 0x40)
+	}
+}
+
+// formatUUID formats 16 bytes as a UUID with the given version and the RFC 9562 variant.
+func formatUUID(b []byte, version byte) string {
+	u := make([]byte, 16)
+	copy(u, b)
+	u[6] = u[6]&0x0f | version
+	u[8] = u[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
+
+// formatULID encodes 16 bytes as a 26-character ULID in Crockford base32.
+func formatULID(b []byte) string {
+	// 128 bits are encoded in 26 characters of 5 bits, so the first character only carries the 3 leading bits
+	out := make([]byte, 26)
+	for i := 25; i >= 0; i-- {
+		bit := 128 - 5*(26-i)
+		var v byte
+		for j := 0; j < 5; j++ {
+			if n := bit + j; n >= 0 && b[n/8]&(0x80>>(n%8)) != 0 {
+				v |= 0x10 >> j
+			}
+		}
+		out[i] = crockfordBase32[v]
+	}
+
+	return string(out)
+}
@@ -5,42 +5,118 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/go-redis/redis/v8"
+
 	"github.com/yoanesber/go-idempotency-with-redis/config/cache"
 )
 
+// deleteIfValueScript deletes a key only when its current value matches the given argument.
+var deleteIfValueScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
 // Set sets a string value in Redis with a specified key and TTL.
-func Set(key string, value string, ttl time.Duration) error {
+func Set(ctx context.Context, key string, value string, ttl time.Duration) error {
 	// Get the Redis client from the context
 	client := cache.GetRedisClient()
 	if client == nil {
 		return fmt.Errorf("redis client is nil")
 	}
 
-	return client.Set(context.Background(), key, value, ttl).Err()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	return wrapError(client.Set(ctx, key, value, ttl).Err())
 }
 
 // Get retrieves a string value from Redis with a specified key.
-func Get(key string) (string, error) {
+func Get(ctx context.Context, key string) (string, error) {
 	// Get the Redis client from the context
 	client := cache.GetRedisClient()
 	if client == nil {
 		return "", fmt.Errorf("redis client is nil")
 	}
 
-	value, err := client.Get(context.Background(), key).Result()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	value, err := client.Get(ctx, key).Result()
 	if err != nil {
-		return "", err
+		return "", wrapError(err)
 	}
 	return value, nil
 }
 
 // DeleteKey deletes a key from Redis.
-func DeleteKey(key string) error {
+// It returns true if the key existed.
+func DeleteKey(ctx context.Context, key string) (bool, error) {
 	// Get the Redis client from the context
 	client := cache.GetRedisClient()
 	if client == nil {
-		return fmt.Errorf("redis client is nil")
+		return false, fmt.Errorf("redis client is nil")
+	}
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	deleted, err := client.Del(ctx, key).Result()
+	if err != nil {
+		return false, wrapError(err)
+	}
+	return deleted > 0, nil
+}
+
+// SetNX sets a string value in Redis only if the key does not already exist.
+// It returns true if the key was set, or false if the key already exists.
+func SetNX(ctx context.Context, key string, value string, ttl time.Duration) (bool, error) {
+	// Get the Redis client from the context
+	client := cache.GetRedisClient()
+	if client == nil {
+		return false, fmt.Errorf("redis client is nil")
+	}
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	acquired, err := client.SetNX(ctx, key, value, ttl).Result()
+	return acquired, wrapError(err)
+}
+
+// DeleteKeyIfValue deletes a key from Redis only if it still holds the expected value.
+// The check and the delete are performed atomically using a Lua script.
+// It returns true if the key was deleted.
+func DeleteKeyIfValue(ctx context.Context, key string, value string) (bool, error) {
+	// Get the Redis client from the context
+	client := cache.GetRedisClient()
+	if client == nil {
+		return false, fmt.Errorf("redis client is nil")
 	}
 
-	return client.Del(context.Background(), key).Err()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	deleted, err := deleteIfValueScript.Run(ctx, client, []string{key}, value).Int64()
+	if err != nil {
+		return false, wrapError(err)
+	}
+	return deleted == 1, nil
+}
+
+// GetTTL retrieves the remaining time to live of a key in Redis.
+// It returns a negative duration if the key has no expiration or does not exist.
+func GetTTL(ctx context.Context, key string) (time.Duration, error) {
+	// Get the Redis client from the context
+	client := cache.GetRedisClient()
+	if client == nil {
+		return 0, fmt.Errorf("redis client is nil")
+	}
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	ttl, err := client.TTL(ctx, key).Result()
+	return ttl, wrapError(err)
 }
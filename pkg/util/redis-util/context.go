@@ -0,0 +1,67 @@
+package redis_util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	breakerutil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/breaker-util"
+	envutil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/env-util"
+)
+
+const (
+	defaultOperationTimeout = 2 * time.Second // Default timeout of a Redis operation, used when REDIS_OPERATION_TIMEOUT_MS is not set
+)
+
+// ErrTimeout is returned when a Redis operation does not complete before its deadline.
+// It is distinct from redis.Nil, which means the key does not exist.
+var ErrTimeout = errors.New("redis operation timed out")
+
+// IsTimeout reports whether the error is a Redis operation timeout.
+func IsTimeout(err error) bool {
+	return errors.Is(err, ErrTimeout)
+}
+
+// IsCircuitOpen reports whether the Redis operation was rejected because the circuit breaker is open.
+func IsCircuitOpen(err error) bool {
+	return errors.Is(err, breakerutil.ErrOpen)
+}
+
+// getOperationTimeout returns the default timeout of a Redis operation from the REDIS_OPERATION_TIMEOUT_MS environment variable.
+// A zero value disables the default timeout, so only the caller's deadline applies.
+func getOperationTimeout() time.Duration {
+	ms := envutil.GetNonNegativeInt("REDIS_OPERATION_TIMEOUT_MS", int(defaultOperationTimeout/time.Millisecond))
+	return time.Duration(ms) * time.Millisecond
+}
+
+// withTimeout derives the context of a single Redis operation from the caller's context.
+// The default operation timeout applies on top of the caller's deadline; the earlier of the two wins.
+func withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	timeout := getOperationTimeout()
+	if timeout == 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// wrapError wraps an expired deadline or a network timeout in ErrTimeout, so it can be told apart from redis.Nil.
+// Other errors, including redis.Nil and a cancellation by the caller, are returned unchanged.
+func wrapError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+
+	return err
+}
@@ -11,7 +11,7 @@ import (
 
 // SetJSON sets a JSON value in Redis with a specified key and TTL.
 // It marshals the value into JSON format and stores it in Redis.
-func SetJSON(key string, value interface{}, ttl time.Duration) error {
+func SetJSON(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
 	// Get the Redis client from the context
 	client := cache.GetRedisClient()
 	if client == nil {
@@ -23,21 +23,27 @@ func SetJSON(key string, value interface{}, ttl time.Duration) error {
 		return err
 	}
 
-	return client.Set(context.Background(), key, data, ttl).Err()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	return wrapError(client.Set(ctx, key, data, ttl).Err())
 }
 
 // GetJSON retrieves a JSON value from Redis with a specified key.
 // It unmarshals the JSON data into the provided value.
-func GetJSON[T any](key string) (*T, error) {
+func GetJSON[T any](ctx context.Context, key string) (*T, error) {
 	// Get the Redis client from the context
 	client := cache.GetRedisClient()
 	if client == nil {
 		return nil, fmt.Errorf("redis client is nil")
 	}
 
-	data, err := client.Get(context.Background(), key).Bytes()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	data, err := client.Get(ctx, key).Bytes()
 	if err != nil {
-		return nil, err
+		return nil, wrapError(err)
 	}
 
 	var result T
@@ -0,0 +1,150 @@
+package redis_util
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/yoanesber/go-idempotency-with-redis/config/cache"
+)
+
+const (
+	clusterCursorShift = 48                                // Bit position of the primary index in a Redis Cluster cursor
+	clusterCursorMask  = uint64(1)<<clusterCursorShift - 1 // Mask of the SCAN cursor of the primary in a Redis Cluster cursor
+)
+
+// KeyInfo describes a Redis key, as returned by DescribeKeys.
+type KeyInfo struct {
+	Key         string `json:"key"`
+	Type        string `json:"type"`
+	TTLSeconds  int64  `json:"ttlSeconds"`  // -1 if the key has no expiration, -2 if the key no longer exists
+	MemoryBytes int64  `json:"memoryBytes"` // 0 if the memory usage is not available
+}
+
+// ScanKeys retrieves one page of keys matching the pattern using SCAN, starting at the given cursor.
+// It returns the keys and the cursor of the next page, which is 0 once the iteration is complete.
+// SCAN is used instead of KEYS, so the iteration never blocks the Redis server.
+func ScanKeys(ctx context.Context, pattern string, cursor uint64, count int64) ([]string, uint64, error) {
+	// Get the Redis client from the context
+	client := cache.GetRedisClient()
+	if client == nil {
+		return nil, 0, fmt.Errorf("redis client is nil")
+	}
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	// In Cluster mode, every primary holds a part of the keyspace, so the primaries are scanned one after another
+	if cluster, ok := client.(*redis.ClusterClient); ok {
+		keys, nextCursor, err := scanCluster(ctx, cluster, pattern, cursor, count)
+		return keys, nextCursor, wrapError(err)
+	}
+
+	keys, nextCursor, err := client.Scan(ctx, cursor, pattern, count).Result()
+	return keys, nextCursor, wrapError(err)
+}
+
+// scanCluster retrieves one page of keys from a Redis Cluster.
+// The upper bits of the cursor hold the index of the primary being scanned and the lower bits hold the SCAN cursor
+// of that primary, so callers page through the whole cluster with a single opaque cursor.
+func scanCluster(ctx context.Context, cluster *redis.ClusterClient, pattern string, cursor uint64, count int64) ([]string, uint64, error) {
+	// Collect the primaries in a stable order, so the node index in the cursor refers to the same node across pages
+	var mu sync.Mutex
+	var nodes []*redis.Client
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+		mu.Lock()
+		nodes = append(nodes, node)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Options().Addr < nodes[j].Options().Addr
+	})
+
+	nodeIndex := int(cursor >> clusterCursorShift)
+	if nodeIndex >= len(nodes) {
+		return []string{}, 0, nil
+	}
+
+	keys, nodeCursor, err := nodes[nodeIndex].Scan(ctx, cursor&clusterCursorMask, pattern, count).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Move on to the next primary once the current one has been scanned completely
+	if nodeCursor == 0 {
+		nodeIndex++
+		if nodeIndex >= len(nodes) {
+			return keys, 0, nil
+		}
+	}
+
+	return keys, uint64(nodeIndex)<<clusterCursorShift | nodeCursor&clusterCursorMask, nil
+}
+
+// DescribeKeys retrieves the type, remaining TTL and memory usage of the given keys.
+// The commands are sent in a single pipeline, so describing a page of keys takes one round trip.
+func DescribeKeys(ctx context.Context, keys []string) ([]KeyInfo, error) {
+	// Get the Redis client from the context
+	client := cache.GetRedisClient()
+	if client == nil {
+		return nil, fmt.Errorf("redis client is nil")
+	}
+
+	if len(keys) == 0 {
+		return []KeyInfo{}, nil
+	}
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	typeCmds := make([]*redis.StatusCmd, len(keys))
+	ttlCmds := make([]*redis.DurationCmd, len(keys))
+	memoryCmds := make([]*redis.IntCmd, len(keys))
+
+	// Queue the commands for all keys
+	// The pipeline only reports the first failed command, so failures are checked per command below
+	_, _ = client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			typeCmds[i] = pipe.Type(ctx, key)
+			ttlCmds[i] = pipe.TTL(ctx, key)
+			memoryCmds[i] = pipe.MemoryUsage(ctx, key)
+		}
+		return nil
+	})
+
+	infos := make([]KeyInfo, 0, len(keys))
+	for i, key := range keys {
+		keyType, err := typeCmds[i].Result()
+		if err != nil {
+			return nil, wrapError(err)
+		}
+
+		info := KeyInfo{Key: key, Type: keyType, TTLSeconds: -1}
+
+		// Redis reports -2 for a key that no longer exists and -1 for a key without expiration
+		if ttl, err := ttlCmds[i].Result(); err == nil {
+			if ttl >= 0 {
+				info.TTLSeconds = int64(ttl.Seconds())
+			} else if ttl == -2 {
+				info.TTLSeconds = -2
+			}
+		}
+
+		// MEMORY USAGE may be disabled on managed Redis services, in which case the memory usage is left at 0
+		if memory, err := memoryCmds[i].Result(); err == nil {
+			info.MemoryBytes = memory
+		}
+
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
@@ -0,0 +1,38 @@
+package redis_util
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/yoanesber/go-idempotency-with-redis/config/cache"
+)
+
+// AddToStream appends an entry with the given fields to a Redis stream (XADD) and returns the ID of the entry.
+// If maxLen is positive, the stream is trimmed to approximately maxLen entries.
+func AddToStream(ctx context.Context, stream string, values map[string]interface{}, maxLen int64) (string, error) {
+	// Get the Redis client from the context
+	client := cache.GetRedisClient()
+	if client == nil {
+		return "", fmt.Errorf("redis client is nil")
+	}
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	args := &redis.XAddArgs{
+		Stream: stream,
+		Values: values,
+	}
+	if maxLen > 0 {
+		args.MaxLen = maxLen
+		args.Approx = true
+	}
+
+	id, err := client.XAdd(ctx, args).Result()
+	if err != nil {
+		return "", wrapError(err)
+	}
+	return id, nil
+}
@@ -41,6 +41,11 @@ func GetValidator() *validator.Validate {
 	return validate
 }
 
+// IsInitialized reports whether the validator has been initialized, without initializing it.
+func IsInitialized() bool {
+	return validate != nil
+}
+
 // ClearValidator clears the validator instance.
 // This function can be used to reset the validator for re-initialization.
 func ClearValidator() {
@@ -7,18 +7,45 @@ import (
 	"github.com/yoanesber/go-idempotency-with-redis/internal/handler"
 	"github.com/yoanesber/go-idempotency-with-redis/internal/repository"
 	"github.com/yoanesber/go-idempotency-with-redis/internal/service"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/store"
+	"github.com/yoanesber/go-idempotency-with-redis/pkg/middleware/auth"
 	"github.com/yoanesber/go-idempotency-with-redis/pkg/middleware/headers"
 	"github.com/yoanesber/go-idempotency-with-redis/pkg/middleware/idempotency"
 	"github.com/yoanesber/go-idempotency-with-redis/pkg/middleware/logging"
 	request_filter "github.com/yoanesber/go-idempotency-with-redis/pkg/middleware/request-filter"
+	"github.com/yoanesber/go-idempotency-with-redis/pkg/middleware/webhook"
 	httputil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/http-util"
 )
 
 // SetupRouter initializes the router and sets up the routes for the application.
-func SetupRouter() *gin.Engine {
+// The idempotency store is used by the idempotency middleware of the routes that enforce idempotency,
+// and the health service by the liveness and readiness probes.
+func SetupRouter(idemStore store.IdempotencyStore, healthService service.HealthService) *gin.Engine {
 	// Create a new Gin router instance
 	r := gin.Default()
 
+	// Routes for the liveness and readiness probes of the orchestrator
+	// They are registered before the middleware below, so probes without an Origin header are not rejected by the CORS middleware
+	healthHandler := handler.NewHealthHandler(healthService)
+	r.GET("/healthz", healthHandler.Liveness)
+	r.GET("/readyz", healthHandler.Readiness)
+
+	// Routes for the callbacks of the transaction processor
+	// They are registered before the middleware below, as server-to-server callbacks send no Origin header,
+	// and are authenticated by their signature instead
+	webhookGroup := r.Group("/api/v1/webhooks", headers.SecurityHeaders(), headers.ContentType(), logging.RequestLogger(), webhook.VerifySignature())
+	{
+		// Initialize the transaction service that applies the reported status changes
+		s := service.NewTransactionService(repository.NewTransactionRepository(), repository.NewTransactionEventRepository(), repository.NewOutboxMessageRepository(), nil)
+		h := handler.NewWebhookHandler(s)
+
+		// Webhooks are deduplicated by their event ID, so a callback delivered more than once is applied only once
+		// The signature is verified first, so a forged webhook can never claim the event ID of a genuine one
+		webhookGroup.POST("/transactions", idempotency.Enforce(idemStore,
+			idempotency.WithKey(idempotency.KeyFromBodyField("eventId")),
+			idempotency.WithScope(idempotency.FixedScope("webhook:transactions"))), h.HandleTransactionWebhook)
+	}
+
 	// Set up middleware for the router
 	// Middleware is used to handle cross-cutting concerns such as logging, security, and request ID generation
 	r.Use(
@@ -56,8 +83,9 @@ func SetupRouter() *gin.Engine {
 			consumerGroup.GET("/suspended", h.GetSuspendedConsumers)
 
 			// The POST and PUT methods are restricted to admin users only
-			consumerGroup.POST("", h.CreateConsumer)
-			consumerGroup.PATCH("/:id", h.UpdateConsumerStatus)
+			// The idempotency key is optional on consumer routes, so existing clients without a key keep working
+			consumerGroup.POST("", idempotency.Enforce(idemStore, idempotency.WithOptionalKey()), h.CreateConsumer)
+			consumerGroup.PATCH("/:id", idempotency.Enforce(idemStore, idempotency.WithOptionalKey()), h.UpdateConsumerStatus)
 		}
 
 		// Routes for transaction management
@@ -67,7 +95,9 @@ func SetupRouter() *gin.Engine {
 			// Initialize the transaction repository and service
 			// This is where the actual implementation of the repository and service would be used
 			r := repository.NewTransactionRepository()
-			s := service.NewTransactionService(r)
+			er := repository.NewTransactionEventRepository()
+			o := repository.NewOutboxMessageRepository()
+			s := service.NewTransactionService(r, er, o, nil)
 
 			// Initialize the transaction handler with the service
 			// This handler handles the HTTP requests and responses for transaction-related operations
@@ -77,9 +107,58 @@ func SetupRouter() *gin.Engine {
 			// These routes handle CRUD operations for transactions
 			trxGroup.GET("", h.GetAllTransactions)
 			trxGroup.GET("/:id", h.GetTransactionByID)
+			trxGroup.GET("/:id/history", h.GetTransactionHistory)
 
 			// The POST and PUT methods are restricted to admin users only
-			trxGroup.POST("", idempotency.Enforce(), h.CreateTransaction)
+			// Idempotency keys are scoped by consumer, so keys chosen by different consumers never collide
+			trxGroup.POST("", idempotency.Enforce(idemStore, idempotency.WithScope(idempotency.ScopeFromBodyField("consumerId"))), h.CreateTransaction)
+
+			// Status transitions are guarded by idempotency, so a retried transition replays its first result
+			// Only legal transitions are allowed (pending -> processing -> completed | failed)
+			// Submitting hands the transaction to the downstream processor and applies its reply
+			trxGroup.POST("/:id/submit", idempotency.Enforce(idemStore), h.SubmitTransaction)
+			trxGroup.POST("/:id/process", idempotency.Enforce(idemStore), h.ProcessTransaction)
+			trxGroup.POST("/:id/complete", idempotency.Enforce(idemStore), h.CompleteTransaction)
+			trxGroup.POST("/:id/fail", idempotency.Enforce(idemStore), h.FailTransaction)
+		}
+
+		// Routes for administration
+		// These routes are restricted to administrators holding the admin token
+		adminGroup := v1.Group("/admin", auth.AdminAuth())
+		{
+			// Routes for inspecting and evicting idempotency keys
+			idemGroup := adminGroup.Group("/idempotency")
+			{
+				// Initialize the idempotency key repository and service
+				// Keys are inspected and evicted through the idempotency store of the middleware, whichever backend it uses
+				r := repository.NewIdempotencyCacheRepository()
+				s := service.NewIdempotencyCacheService(r, idemStore)
+
+				// Initialize the idempotency key handler with the service
+				// This handler lets support engineers inspect and evict idempotency keys
+				h := handler.NewIdempotencyCacheHandler(s)
+
+				idemGroup.GET("", h.GetAllIdempotencyCaches)
+				idemGroup.GET("/:key", h.GetIdempotencyCacheByKey)
+				idemGroup.DELETE("/:key", h.DeleteIdempotencyCache)
+			}
+
+			// Routes for inspecting Redis data during incidents
+			dataRedisGroup := adminGroup.Group("/dataredis")
+			{
+				// Initialize the Redis data service and handler
+				// Only keys with an allow-listed prefix can be read, so the routes cannot be used to read arbitrary data
+				s := service.NewDataRedisService()
+				h := handler.NewDataRedisHandler(s)
+
+				dataRedisGroup.GET("/string/:key", h.GetStringValue)
+				dataRedisGroup.GET("/json/:key", h.GetJSONValue)
+				dataRedisGroup.GET("/hash/:key", h.GetHashValue)
+				dataRedisGroup.GET("/list/:key", h.GetListValues)
+				dataRedisGroup.GET("/set/:key", h.GetSetMembers)
+				dataRedisGroup.GET("/ttl/:key", h.GetTTL)
+				dataRedisGroup.GET("/keys", h.ListKeys)
+			}
 		}
 	}
 
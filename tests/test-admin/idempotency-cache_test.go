@@ -0,0 +1,124 @@
+package test_admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-idempotency-with-redis/internal/entity"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/handler"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/repository"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/service"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/store"
+)
+
+// stubIdempotencyCacheService records the keys it is called with and finds every key.
+type stubIdempotencyCacheService struct {
+	keys []string
+}
+
+func (s *stubIdempotencyCacheService) GetAllIdempotencyCaches(ctx context.Context, page int, limit int, filter entity.IdempotencyCacheFilter) ([]entity.IdempotencyCache, error) {
+	return nil, nil
+}
+
+func (s *stubIdempotencyCacheService) GetIdempotencyCacheByKey(ctx context.Context, scope string, key string) (entity.IdempotencyCache, error) {
+	s.keys = append(s.keys, key)
+	return entity.IdempotencyCache{Scope: scope, Key: key}, nil
+}
+
+func (s *stubIdempotencyCacheService) DeleteIdempotencyCache(ctx context.Context, scope string, key string) error {
+	s.keys = append(s.keys, key)
+	return nil
+}
+
+func (s *stubIdempotencyCacheService) ClaimIdempotencyCache(ctx context.Context, tx *gorm.DB) (entity.IdempotencyCache, error) {
+	return entity.IdempotencyCache{}, nil
+}
+
+// setupRouter creates a router with the admin idempotency key routes backed by the given service.
+func setupRouter(svc service.IdempotencyCacheService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	h := handler.NewIdempotencyCacheHandler(svc)
+	router.GET("/api/v1/admin/idempotency", h.GetAllIdempotencyCaches)
+	router.GET("/api/v1/admin/idempotency/:key", h.GetIdempotencyCacheByKey)
+	router.DELETE("/api/v1/admin/idempotency/:key", h.DeleteIdempotencyCache)
+
+	return router
+}
+
+// sendRequest sends a request to the admin route of the given key and returns the status code.
+func sendRequest(router *gin.Engine, method string, key string) int {
+	req, _ := http.NewRequest(method, "/api/v1/admin/idempotency/"+key, nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestIdempotencyCacheHandler_RejectsInvalidKey(t *testing.T) {
+	t.Setenv("IDEMPOTENCY_KEY_FORMAT", "UUID4")
+	svc := &stubIdempotencyCacheService{}
+	router := setupRouter(svc)
+
+	assert.Equal(t, http.StatusBadRequest, sendRequest(router, "GET", "not-a-uuid"))
+	assert.Equal(t, http.StatusBadRequest, sendRequest(router, "DELETE", "not-a-uuid"))
+	assert.Empty(t, svc.keys)
+
+	assert.Equal(t, http.StatusOK, sendRequest(router, "GET", "06f14f72-dfba-49ca-aa4e-d85b532ca0b7"))
+	assert.Equal(t, http.StatusOK, sendRequest(router, "DELETE", "06f14f72-dfba-49ca-aa4e-d85b532ca0b7"))
+	assert.Len(t, svc.keys, 2)
+}
+
+func TestIdempotencyCacheHandler_NormalizesKey(t *testing.T) {
+	t.Setenv("IDEMPOTENCY_KEY_FORMAT", "ULID")
+	svc := &stubIdempotencyCacheService{}
+	router := setupRouter(svc)
+
+	assert.Equal(t, http.StatusOK, sendRequest(router, "DELETE", "01arz3ndektsv4rrffq69g5fav"))
+	assert.Equal(t, []string{"01ARZ3NDEKTSV4RRFFQ69G5FAV"}, svc.keys)
+}
+
+func TestIdempotencyCacheHandler_UsesIdempotencyStore(t *testing.T) {
+	t.Setenv("IDEMPOTENCY_KEY_FORMAT", "UUID4")
+	const key = "06f14f72-dfba-49ca-aa4e-d85b532ca0b7"
+
+	// A request crashed after reserving the key and claiming it, leaving both behind in the memory store
+	ctx := context.Background()
+	idemStore := store.NewMemoryStore()
+	_, err := idemStore.Reserve(ctx, "global", key, time.Hour)
+	assert.NoError(t, err)
+	_, err = idemStore.Complete(ctx, entity.IdempotencyCache{Scope: "global", Key: key, CreatedAt: time.Now(), ExpiredAt: time.Now().Add(time.Hour)})
+	assert.NoError(t, err)
+
+	router := setupRouter(service.NewIdempotencyCacheService(repository.NewIdempotencyCacheRepository(), idemStore))
+
+	req, _ := http.NewRequest("GET", "/api/v1/admin/idempotency", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Data []entity.IdempotencyCache `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Len(t, resp.Data, 1)
+
+	assert.Equal(t, http.StatusOK, sendRequest(router, "GET", key))
+	assert.Equal(t, http.StatusOK, sendRequest(router, "DELETE", key))
+
+	// The record and the stuck reservation are both gone, so a retry with the key is processed again
+	assert.Equal(t, http.StatusNotFound, sendRequest(router, "GET", key))
+	assert.Equal(t, http.StatusNotFound, sendRequest(router, "DELETE", key))
+
+	reservation, err := idemStore.Reserve(ctx, "global", key, time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, reservation.Acquired)
+}
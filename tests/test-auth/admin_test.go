@@ -0,0 +1,53 @@
+package test_auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yoanesber/go-idempotency-with-redis/pkg/middleware/auth"
+)
+
+// setupRouter creates a router with a single admin route protected by the admin auth middleware.
+func setupRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/admin/idempotency", auth.AdminAuth(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	return router
+}
+
+// sendRequest sends a request to the admin route with the given Authorization header and returns the status code.
+func sendRequest(router *gin.Engine, authorization string) int {
+	req, _ := http.NewRequest("GET", "/api/v1/admin/idempotency", nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestAdminAuth(t *testing.T) {
+	t.Setenv("ADMIN_API_TOKEN", "s3cret")
+	router := setupRouter()
+
+	assert.Equal(t, http.StatusOK, sendRequest(router, "Bearer s3cret"))
+	assert.Equal(t, http.StatusUnauthorized, sendRequest(router, "Bearer wrong"))
+	assert.Equal(t, http.StatusUnauthorized, sendRequest(router, "s3cret"))
+	assert.Equal(t, http.StatusUnauthorized, sendRequest(router, ""))
+}
+
+func TestAdminAuth_DisabledWithoutToken(t *testing.T) {
+	t.Setenv("ADMIN_API_TOKEN", "")
+	router := setupRouter()
+
+	assert.Equal(t, http.StatusForbidden, sendRequest(router, "Bearer "))
+	assert.Equal(t, http.StatusForbidden, sendRequest(router, "Bearer anything"))
+}
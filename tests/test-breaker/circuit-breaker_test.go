@@ -0,0 +1,123 @@
+package test_breaker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yoanesber/go-idempotency-with-redis/config/cache"
+	breakerutil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/breaker-util"
+)
+
+func TestCircuitBreaker_OpensAfterConsecutiveFailures(t *testing.T) {
+	b := breakerutil.NewCircuitBreaker("test", 3, time.Minute)
+
+	// A success resets the count of consecutive failures
+	for _, failed := range []bool{true, true, false, true, true} {
+		assert.NoError(t, b.Allow())
+		b.Record(failed)
+	}
+	assert.Equal(t, breakerutil.StateClosed, b.State())
+
+	assert.NoError(t, b.Allow())
+	b.Record(true)
+
+	assert.Equal(t, breakerutil.StateOpen, b.State())
+	assert.ErrorIs(t, b.Allow(), breakerutil.ErrOpen)
+}
+
+func TestCircuitBreaker_ProbesAfterOpenTimeout(t *testing.T) {
+	b := breakerutil.NewCircuitBreaker("test", 1, 50*time.Millisecond)
+
+	assert.NoError(t, b.Allow())
+	b.Record(true)
+	assert.Equal(t, breakerutil.StateOpen, b.State())
+
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, breakerutil.StateHalfOpen, b.State())
+
+	// A single probe goes through; a failed probe opens the circuit again
+	assert.NoError(t, b.Allow())
+	assert.ErrorIs(t, b.Allow(), breakerutil.ErrOpen)
+	b.Record(true)
+	assert.Equal(t, breakerutil.StateOpen, b.State())
+
+	// A successful probe closes the circuit
+	time.Sleep(60 * time.Millisecond)
+	assert.NoError(t, b.Allow())
+	b.Record(false)
+	assert.Equal(t, breakerutil.StateClosed, b.State())
+	assert.NoError(t, b.Allow())
+}
+
+func TestBreakerHook_FailsFastWhenRedisIsDown(t *testing.T) {
+	b := breakerutil.NewCircuitBreaker("redis", 2, time.Minute)
+
+	// Nothing listens on this address, so every command fails to connect
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer client.Close()
+	client.AddHook(cache.NewBreakerHook(b))
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		err := client.Get(ctx, "idempotency_cache:global:key").Err()
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, breakerutil.ErrOpen)
+	}
+
+	assert.Equal(t, breakerutil.StateOpen, b.State())
+	assert.ErrorIs(t, client.Get(ctx, "idempotency_cache:global:key").Err(), breakerutil.ErrOpen)
+
+	pipe := client.Pipeline()
+	pipe.Get(ctx, "idempotency_cache:global:key")
+	_, err := pipe.Exec(ctx)
+	assert.ErrorIs(t, err, breakerutil.ErrOpen)
+}
+
+func TestCircuitBreaker_ReleasedProbeIsNeutral(t *testing.T) {
+	b := breakerutil.NewCircuitBreaker("test", 1, 50*time.Millisecond)
+
+	assert.NoError(t, b.Allow())
+	b.Record(true)
+	time.Sleep(60 * time.Millisecond)
+
+	// A released probe neither closes nor opens the circuit, and the next call probes again
+	assert.NoError(t, b.Allow())
+	b.Release()
+	assert.Equal(t, breakerutil.StateHalfOpen, b.State())
+	assert.NoError(t, b.Allow())
+	assert.ErrorIs(t, b.Allow(), breakerutil.ErrOpen)
+}
+
+func TestBreakerHook_CancelledProbeDoesNotCloseCircuit(t *testing.T) {
+	b := breakerutil.NewCircuitBreaker("redis", 1, 50*time.Millisecond)
+
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer client.Close()
+	client.AddHook(cache.NewBreakerHook(b))
+
+	assert.NoError(t, b.Allow())
+	b.Record(true)
+	time.Sleep(60 * time.Millisecond)
+
+	// The probe is cancelled by its caller, which says nothing about whether Redis has recovered
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, client.Get(ctx, "idempotency_cache:global:key").Err(), context.Canceled)
+	assert.Equal(t, breakerutil.StateHalfOpen, b.State())
+
+	pipe := client.Pipeline()
+	pipe.Get(ctx, "idempotency_cache:global:key")
+	_, err := pipe.Exec(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, breakerutil.StateHalfOpen, b.State())
+
+	// The probe slot was released, so the next command probes Redis, which is still down
+	err = client.Get(context.Background(), "idempotency_cache:global:key").Err()
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, breakerutil.ErrOpen)
+	assert.Equal(t, breakerutil.StateOpen, b.State())
+}
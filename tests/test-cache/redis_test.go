@@ -0,0 +1,132 @@
+package test_cache
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yoanesber/go-idempotency-with-redis/config/cache"
+)
+
+func TestRedis_CreatesClientPerMode(t *testing.T) {
+	cases := map[string]map[string]string{
+		cache.RedisModeStandalone: {"REDIS_HOST": "localhost", "REDIS_PORT": "6379", "REDIS_DB": "0"},
+		cache.RedisModeSentinel:   {"REDIS_ADDRS": "sentinel-1:26379, sentinel-2:26379", "REDIS_MASTER_NAME": "mymaster", "REDIS_DB": "1"},
+		cache.RedisModeCluster:    {"REDIS_ADDRS": "node-1:6379"},
+	}
+
+	for mode, env := range cases {
+		t.Setenv("REDIS_MODE", mode)
+		for _, name := range []string{"REDIS_HOST", "REDIS_PORT", "REDIS_DB", "REDIS_ADDRS", "REDIS_MASTER_NAME"} {
+			t.Setenv(name, env[name])
+		}
+
+		// Creating a client does not connect, so no Redis server is needed
+		assert.True(t, cache.LoadRedisEnv(), mode)
+		opts, err := cache.NewRedisOptions()
+		assert.NoError(t, err, mode)
+
+		client := cache.NewRedisClient(opts)
+		switch mode {
+		case cache.RedisModeCluster:
+			assert.IsType(t, &redis.ClusterClient{}, client, mode)
+		default:
+			// A Sentinel-managed primary is served by a failover client, which is a *redis.Client
+			assert.IsType(t, &redis.Client{}, client, mode)
+		}
+		assert.NoError(t, client.Close())
+	}
+}
+
+func TestRedis_AppliesPoolAndTimeouts(t *testing.T) {
+	t.Setenv("REDIS_MODE", "")
+	t.Setenv("REDIS_HOST", "localhost")
+	t.Setenv("REDIS_PORT", "6379")
+	t.Setenv("REDIS_DB", "2")
+	t.Setenv("REDIS_POOL_SIZE", "25")
+	t.Setenv("REDIS_READ_TIMEOUT_MS", "1500")
+
+	assert.True(t, cache.LoadRedisEnv())
+	opts, err := cache.NewRedisOptions()
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"localhost:6379"}, opts.Addrs)
+	assert.Equal(t, 2, opts.DB)
+	assert.Equal(t, 25, opts.PoolSize)
+	assert.Equal(t, 1500*time.Millisecond, opts.ReadTimeout)
+	assert.Nil(t, opts.TLSConfig)
+}
+
+func TestRedis_ClusterRejectsNonZeroDB(t *testing.T) {
+	t.Setenv("REDIS_MODE", cache.RedisModeCluster)
+	t.Setenv("REDIS_ADDRS", "node-1:6379,node-2:6379")
+	t.Setenv("REDIS_DB", "3")
+
+	assert.True(t, cache.LoadRedisEnv())
+	_, err := cache.NewRedisOptions()
+	assert.Error(t, err)
+}
+
+func TestRedis_LoadsTLSConfig(t *testing.T) {
+	t.Setenv("REDIS_TLS_ENABLED", "TRUE")
+	t.Setenv("REDIS_TLS_SERVER_NAME", "redis.internal")
+
+	// A missing CA file is reported instead of silently falling back to the system roots
+	t.Setenv("REDIS_TLS_CA_FILE", filepath.Join(t.TempDir(), "missing.pem"))
+	_, err := cache.LoadRedisTLSConfig()
+	assert.Error(t, err)
+
+	// A client certificate requires its key
+	certFile, keyFile := writeCertificate(t)
+	t.Setenv("REDIS_TLS_CA_FILE", certFile)
+	t.Setenv("REDIS_TLS_CERT_FILE", certFile)
+	t.Setenv("REDIS_TLS_KEY_FILE", "")
+	_, err = cache.LoadRedisTLSConfig()
+	assert.Error(t, err)
+
+	t.Setenv("REDIS_TLS_KEY_FILE", keyFile)
+	tlsConfig, err := cache.LoadRedisTLSConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, "redis.internal", tlsConfig.ServerName)
+	assert.NotNil(t, tlsConfig.RootCAs)
+	assert.Len(t, tlsConfig.Certificates, 1)
+}
+
+// writeCertificate writes a self-signed certificate and its private key to PEM files and returns their paths.
+func writeCertificate(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "redis.internal"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "redis.crt")
+	keyFile := filepath.Join(dir, "redis.key")
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+
+	return certFile, keyFile
+}
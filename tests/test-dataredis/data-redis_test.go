@@ -0,0 +1,76 @@
+package test_dataredis
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yoanesber/go-idempotency-with-redis/internal/handler"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/service"
+)
+
+func TestDataRedis_RejectsKeysOutsideAllowList(t *testing.T) {
+	t.Setenv("DATAREDIS_ALLOWED_PREFIXES", "idempotency_cache:, outbox:")
+
+	// The allow-list is checked before Redis is accessed, so no Redis connection is needed
+	s := service.NewDataRedisService()
+	h := handler.NewDataRedisHandler(s)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/admin/dataredis/string/:key", h.GetStringValue)
+	router.GET("/api/v1/admin/dataredis/json/:key", h.GetJSONValue)
+	router.GET("/api/v1/admin/dataredis/hash/:key", h.GetHashValue)
+	router.GET("/api/v1/admin/dataredis/list/:key", h.GetListValues)
+	router.GET("/api/v1/admin/dataredis/set/:key", h.GetSetMembers)
+	router.GET("/api/v1/admin/dataredis/ttl/:key", h.GetTTL)
+
+	for _, kind := range []string{"string", "json", "hash", "list", "set", "ttl"} {
+		req, _ := http.NewRequest("GET", "/api/v1/admin/dataredis/"+kind+"/session:admin", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code, kind)
+	}
+}
+
+func TestDataRedis_DefaultsToIdempotencyPrefix(t *testing.T) {
+	t.Setenv("DATAREDIS_ALLOWED_PREFIXES", "")
+	t.Setenv("IDEMPOTENCY_PREFIX", "idempotency_cache:")
+
+	s := service.NewDataRedisService()
+
+	_, err := s.GetStringValue(context.Background(), "session:admin")
+	assert.ErrorIs(t, err, service.ErrRedisKeyNotAllowed)
+}
+
+func TestDataRedis_ListKeysValidatesPattern(t *testing.T) {
+	t.Setenv("DATAREDIS_ALLOWED_PREFIXES", "idempotency_cache:")
+
+	s := service.NewDataRedisService()
+	h := handler.NewDataRedisHandler(s)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/admin/dataredis/keys", h.ListKeys)
+
+	cases := map[string]int{
+		"":                                       http.StatusBadRequest,
+		"?pattern=*":                             http.StatusForbidden,
+		"?pattern=idempotency_cache*":            http.StatusForbidden,
+		"?pattern=idempotency_cache:*&count=0":   http.StatusBadRequest,
+		"?pattern=idempotency_cache:*&cursor=-1": http.StatusBadRequest,
+	}
+
+	for query, status := range cases {
+		req, _ := http.NewRequest("GET", "/api/v1/admin/dataredis/keys"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, status, w.Code, query)
+	}
+}
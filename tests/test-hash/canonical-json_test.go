@@ -0,0 +1,87 @@
+package test_hash
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	hashutil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/hash-util"
+)
+
+func TestCanonicalJSON_EquivalentBodies(t *testing.T) {
+	// Each case lists bodies that are semantically equal and must produce the same canonical form
+	cases := map[string][]string{
+		"reordered keys": {
+			`{"type":"payment","amount":100,"consumerId":"abc"}`,
+			`{"consumerId":"abc","amount":100,"type":"payment"}`,
+		},
+		"whitespace": {
+			`{"type":"payment","amount":100}`,
+			"{\n  \"type\" : \"payment\",\n  \"amount\" : 100\n}\n",
+		},
+		"normalized numbers": {
+			`{"amount":100}`,
+			`{"amount":100.0}`,
+			`{"amount":1e2}`,
+			`{"amount":1.00E+2}`,
+		},
+		"negative zero": {
+			`{"amount":0}`,
+			`{"amount":-0.0}`,
+		},
+		"nested objects": {
+			`{"meta":{"b":[1,{"y":2,"x":1}],"a":true}}`,
+			`{"meta":{"a":true,"b":[1.0,{"x":1,"y":2.0}]}}`,
+		},
+	}
+
+	for name, bodies := range cases {
+		t.Run(name, func(t *testing.T) {
+			expected, err := hashutil.CanonicalJSON([]byte(bodies[0]))
+			assert.NoError(t, err)
+
+			for _, body := range bodies[1:] {
+				actual, err := hashutil.CanonicalJSON([]byte(body))
+				assert.NoError(t, err)
+				assert.Equal(t, string(expected), string(actual), "Expected %s to be equivalent to %s", body, bodies[0])
+			}
+		})
+	}
+}
+
+func TestCanonicalJSON_Output(t *testing.T) {
+	canonical, err := hashutil.CanonicalJSON([]byte(` { "b" : 1.50 , "a" : [ "x" , null , false ] } `))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"a":["x",null,false],"b":1.5}`, string(canonical))
+}
+
+func TestCanonicalJSON_DifferentBodies(t *testing.T) {
+	// Bodies that differ semantically must not produce the same hash
+	cases := [][2]string{
+		{`{"amount":100}`, `{"amount":100.01}`},
+		{`{"amount":100}`, `{"amount":"100"}`},
+		{`[1,2]`, `[2,1]`},
+		{`{"a":null}`, `{}`},
+	}
+
+	for _, pair := range cases {
+		first, err := hashutil.HashCanonicalJSON([]byte(pair[0]))
+		assert.NoError(t, err)
+		second, err := hashutil.HashCanonicalJSON([]byte(pair[1]))
+		assert.NoError(t, err)
+		assert.NotEqual(t, first, second, "Expected %s and %s to differ", pair[0], pair[1])
+	}
+}
+
+func TestCanonicalJSON_InvalidJSON(t *testing.T) {
+	invalidBodies := []string{
+		``,
+		`{"amount":}`,
+		`{"amount":100} {"amount":100}`,
+	}
+
+	for _, body := range invalidBodies {
+		_, err := hashutil.CanonicalJSON([]byte(body))
+		assert.Error(t, err, "Expected %q to be rejected", body)
+	}
+}
@@ -0,0 +1,93 @@
+package test_health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yoanesber/go-idempotency-with-redis/internal/service"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/store"
+	"github.com/yoanesber/go-idempotency-with-redis/routes"
+)
+
+// healthResponse is the part of the probe response checked by the tests.
+type healthResponse struct {
+	Data service.HealthReport `json:"data"`
+}
+
+// check creates a dependency check that fails with the given error.
+func check(name string, critical bool, err error) service.HealthCheck {
+	return service.HealthCheck{
+		Name:     name,
+		Critical: critical,
+		Check:    func(ctx context.Context) error { return err },
+	}
+}
+
+// probe sends a GET request without an Origin header to the probe path of the application router.
+func probe(t *testing.T, healthService service.HealthService, path string) (*httptest.ResponseRecorder, service.HealthReport) {
+	gin.SetMode(gin.TestMode)
+	router := routes.SetupRouter(store.NewMemoryStore(), healthService)
+
+	req, _ := http.NewRequest("GET", path, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var resp healthResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+	return w, resp.Data
+}
+
+func TestHealth_ReadyWhenDependenciesAreUp(t *testing.T) {
+	s := service.NewHealthService(check("postgres", true, nil), check("redis", true, nil))
+
+	w, report := probe(t, s, "/readyz")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, service.HealthStatusUp, report.Status)
+	assert.Len(t, report.Dependencies, 2)
+	for _, dependency := range report.Dependencies {
+		assert.Equal(t, service.HealthStatusUp, dependency.Status)
+		assert.GreaterOrEqual(t, dependency.LatencyMs, 0.0)
+	}
+}
+
+func TestHealth_NotReadyWhenCriticalDependencyIsDown(t *testing.T) {
+	s := service.NewHealthService(check("postgres", true, errors.New("connection refused")), check("redis", true, nil))
+
+	w, report := probe(t, s, "/readyz")
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, service.HealthStatusDown, report.Status)
+	assert.Equal(t, "connection refused", report.Dependencies[0].Error)
+}
+
+func TestHealth_DegradedWhenNonCriticalDependencyIsDown(t *testing.T) {
+	s := service.NewHealthService(check("postgres", true, nil), check("redis", false, errors.New("circuit breaker is open")))
+
+	w, report := probe(t, s, "/readyz")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, service.HealthStatusDegraded, report.Status)
+}
+
+func TestHealth_NotReadyOnceShutdownHasBegun(t *testing.T) {
+	s := service.NewHealthService(check("postgres", true, nil))
+	s.MarkShuttingDown()
+
+	w, report := probe(t, s, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.True(t, report.ShuttingDown)
+
+	// The process is still alive while it drains
+	w, report = probe(t, s, "/healthz")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, service.HealthStatusUp, report.Status)
+}
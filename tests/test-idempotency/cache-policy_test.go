@@ -0,0 +1,34 @@
+package test_idempotency
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yoanesber/go-idempotency-with-redis/pkg/middleware/idempotency"
+)
+
+func TestCachePolicy_Default(t *testing.T) {
+	policy := idempotency.NewCachePolicy()
+
+	// Successful responses and business rejections are stored
+	for _, status := range []int{http.StatusOK, http.StatusCreated, http.StatusBadRequest, http.StatusNotFound, http.StatusUnprocessableEntity} {
+		assert.Equal(t, idempotency.CacheStore, policy(status), "status %d", status)
+	}
+
+	// Server errors and retryable client errors release the key
+	for _, status := range []int{http.StatusUnauthorized, http.StatusConflict, http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusServiceUnavailable} {
+		assert.Equal(t, idempotency.CacheRelease, policy(status), "status %d", status)
+	}
+}
+
+func TestCachePolicy_RetryableStatusCodesFromEnv(t *testing.T) {
+	t.Setenv("IDEMPOTENCY_RETRYABLE_STATUS_CODES", "404, 429")
+	policy := idempotency.NewCachePolicy()
+
+	assert.Equal(t, idempotency.CacheRelease, policy(http.StatusNotFound))
+	assert.Equal(t, idempotency.CacheRelease, policy(http.StatusTooManyRequests))
+	assert.Equal(t, idempotency.CacheStore, policy(http.StatusConflict))
+	assert.Equal(t, idempotency.CacheRelease, policy(http.StatusInternalServerError))
+}
@@ -0,0 +1,133 @@
+package test_idempotency
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yoanesber/go-idempotency-with-redis/internal/entity"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/repository"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/store"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/worker"
+)
+
+// purgeRecordingStore sends the batch size of every purge to a channel.
+type purgeRecordingStore struct {
+	store.IdempotencyStore
+	purges chan int
+}
+
+func (s *purgeRecordingStore) Purge(ctx context.Context, batchSize int) (int64, error) {
+	s.purges <- batchSize
+	return 0, nil
+}
+
+func TestIdempotencyJanitor_PurgesOnStartUntilStopped(t *testing.T) {
+	t.Setenv("IDEMPOTENCY_PURGE_INTERVAL_MINUTES", "60")
+	t.Setenv("IDEMPOTENCY_PURGE_BATCH_SIZE", "250")
+
+	idemStore := &purgeRecordingStore{purges: make(chan int, 1)}
+	ctx, cancel := context.WithCancel(context.Background())
+	janitor := worker.NewIdempotencyJanitor(idemStore)
+	janitor.Start(ctx)
+
+	// Expired keys are purged once at startup, in batches of the configured size
+	select {
+	case batchSize := <-idemStore.purges:
+		assert.Equal(t, 250, batchSize)
+	case <-time.After(5 * time.Second):
+		t.Fatal("janitor did not purge at startup")
+	}
+
+	// The janitor stops once its context is cancelled, without waiting for the next interval
+	cancel()
+	stopped := make(chan struct{})
+	go func() {
+		janitor.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("janitor did not stop after its context was cancelled")
+	}
+	assert.Empty(t, idemStore.purges)
+}
+
+func TestMemoryStore_PurgesInBatches(t *testing.T) {
+	ctx := context.Background()
+	idemStore := store.NewMemoryStore()
+
+	for i := 0; i < 5; i++ {
+		_, err := idemStore.Complete(ctx, entity.IdempotencyCache{Scope: "global", Key: fmt.Sprintf("expired-%d", i), ExpiredAt: time.Now().Add(-time.Minute)})
+		assert.NoError(t, err)
+	}
+	_, err := idemStore.Complete(ctx, entity.IdempotencyCache{Scope: "global", Key: "unexpired", ExpiredAt: time.Now().Add(time.Hour)})
+	assert.NoError(t, err)
+
+	// The batch size must be positive, as it is for the Postgres store
+	_, err = idemStore.Purge(ctx, 0)
+	assert.Error(t, err)
+
+	// A cancelled purge stops before the first batch
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	deleted, err := idemStore.Purge(cancelled, 2)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Zero(t, deleted)
+
+	// Batches of two delete every expired record, the last batch being smaller than the batch size
+	deleted, err = idemStore.Purge(ctx, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), deleted)
+
+	records, err := idemStore.List(ctx, 1, 10, entity.IdempotencyCacheFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "unexpired", records[0].Key)
+}
+
+func TestPostgresStore_PurgesInBatches(t *testing.T) {
+	mock := useMockedPostgres(t)
+
+	// Expired keys are deleted in batches until a batch comes back smaller than the batch size, under the advisory lock
+	mock.ExpectQuery(`SELECT pg_try_advisory_lock\(\$1\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "idempotency_lock"`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	for _, rows := range []int64{2, 2, 1} {
+		mock.ExpectBegin()
+		mock.ExpectExec(`DELETE FROM "idempotency_cache" WHERE \(scope, key\) IN \(SELECT "scope","key" FROM "idempotency_cache" WHERE expired_at < \$1 ORDER BY expired_at LIMIT \$2\)`).
+			WillReturnResult(sqlmock.NewResult(0, rows))
+		mock.ExpectCommit()
+	}
+	mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1\)`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	idemStore := store.NewPostgresStore(repository.NewIdempotencyCacheRepository(), repository.NewIdempotencyLockRepository())
+	deleted, err := idemStore.Purge(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresStore_SkipsPurgeWhileAnotherReplicaPurges(t *testing.T) {
+	mock := useMockedPostgres(t)
+
+	// Another replica holds the advisory lock, so nothing is deleted
+	mock.ExpectQuery(`SELECT pg_try_advisory_lock\(\$1\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(false))
+
+	idemStore := store.NewPostgresStore(repository.NewIdempotencyCacheRepository(), repository.NewIdempotencyLockRepository())
+	deleted, err := idemStore.Purge(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Zero(t, deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,97 @@
+package test_idempotency
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	keyutil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/key-util"
+)
+
+func TestKeyPolicy_Formats(t *testing.T) {
+	cases := []struct {
+		format  keyutil.KeyFormat
+		valid   []string
+		invalid []string
+	}{
+		{
+			format:  keyutil.KeyFormatUUID4,
+			valid:   []string{"06f14f72-dfba-49ca-aa4e-d85b532ca0b7", "06F14F72-DFBA-49CA-AA4E-D85B532CA0B7"},
+			invalid: []string{"0190163d-8694-739b-aea5-966c26f8ad91", "not-a-uuid", "06f14f72dfba49caaa4ed85b532ca0b7"},
+		},
+		{
+			format:  keyutil.KeyFormatUUID7,
+			valid:   []string{"0190163d-8694-739b-aea5-966c26f8ad91"},
+			invalid: []string{"06f14f72-dfba-49ca-aa4e-d85b532ca0b7"},
+		},
+		{
+			format:  keyutil.KeyFormatULID,
+			valid:   []string{"01ARZ3NDEKTSV4RRFFQ69G5FAV"},
+			invalid: []string{"01ARZ3NDEKTSV4RRFFQ69G5FAU1", "81ARZ3NDEKTSV4RRFFQ69G5FAV", "01ARZ3NDEKTSV4RRFFQ69G5FAI"},
+		},
+		{
+			format:  keyutil.KeyFormatOpaque,
+			valid:   []string{"order-42:retry", "06f14f72-dfba-49ca-aa4e-d85b532ca0b7"},
+			invalid: []string{"has space", "tab\tkey", strings.Repeat("a", 256)},
+		},
+	}
+
+	for _, tc := range cases {
+		policy := keyutil.KeyPolicy{Format: tc.format, MaxLength: 255}
+		for _, key := range tc.valid {
+			assert.NoError(t, policy.Validate(key), "%s: %s", tc.format, key)
+		}
+		for _, key := range tc.invalid {
+			assert.Error(t, policy.Validate(key), "%s: %s", tc.format, key)
+		}
+	}
+}
+
+func TestKeyPolicy_FromEnv(t *testing.T) {
+	t.Setenv("IDEMPOTENCY_KEY_FORMAT", "OPAQUE")
+	t.Setenv("IDEMPOTENCY_KEY_MAX_LENGTH", "64")
+	policy := keyutil.NewKeyPolicy()
+
+	assert.Equal(t, keyutil.KeyFormatOpaque, policy.Format)
+	assert.Equal(t, "varchar(64)", policy.ColumnType())
+	assert.Error(t, policy.Validate(strings.Repeat("a", 65)))
+
+	// Unknown formats fall back to UUIDv4
+	t.Setenv("IDEMPOTENCY_KEY_FORMAT", "UNKNOWN")
+	assert.Equal(t, keyutil.KeyFormatUUID4, keyutil.NewKeyPolicy().Format)
+	assert.Equal(t, "uuid", keyutil.NewKeyPolicy().ColumnType())
+}
+
+func TestKeyPolicy_Normalize(t *testing.T) {
+	ulid := keyutil.KeyPolicy{Format: keyutil.KeyFormatULID, MaxLength: 255}
+	assert.Equal(t, "01ARZ3NDEKTSV4RRFFQ69G5FAV", ulid.Normalize("01arz3ndektsv4rrffq69g5fav"))
+	assert.Equal(t, "01ARZ3NDEKTSV4RRFFQ69G5FAV", ulid.Normalize("01ARZ3NDEKTSV4RRFFQ69G5FAV"))
+
+	uuid4 := keyutil.KeyPolicy{Format: keyutil.KeyFormatUUID4, MaxLength: 255}
+	assert.Equal(t, "06f14f72-dfba-49ca-aa4e-d85b532ca0b7", uuid4.Normalize("06F14F72-DFBA-49CA-AA4E-D85B532CA0B7"))
+
+	// Opaque keys are case-sensitive
+	opaque := keyutil.KeyPolicy{Format: keyutil.KeyFormatOpaque, MaxLength: 255}
+	assert.Equal(t, "Order-42", opaque.Normalize("Order-42"))
+}
+
+func TestKeyPolicy_DerivesValidKeys(t *testing.T) {
+	formats := []keyutil.KeyFormat{keyutil.KeyFormatUUID4, keyutil.KeyFormatUUID7, keyutil.KeyFormatULID, keyutil.KeyFormatOpaque}
+	ids := []string{"evt_1NvXb2", "event id with spaces", strings.Repeat("x", 300)}
+
+	for _, format := range formats {
+		policy := keyutil.KeyPolicy{Format: format, MaxLength: 255}
+		for _, id := range ids {
+			key := policy.Derive(id)
+			assert.NoError(t, policy.Validate(key), "%s: %s", format, id)
+
+			// The same identifier always maps to the same key, and different identifiers to different keys
+			assert.Equal(t, key, policy.Derive(id))
+			assert.NotEqual(t, key, policy.Derive(id+"-other"))
+		}
+	}
+
+	// A valid opaque identifier is kept as is
+	assert.Equal(t, "evt_1NvXb2", keyutil.KeyPolicy{Format: keyutil.KeyFormatOpaque, MaxLength: 255}.Derive("evt_1NvXb2"))
+}
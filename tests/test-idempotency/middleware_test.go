@@ -0,0 +1,404 @@
+package test_idempotency
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yoanesber/go-idempotency-with-redis/internal/entity"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/store"
+	"github.com/yoanesber/go-idempotency-with-redis/pkg/middleware/idempotency"
+	breakerutil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/breaker-util"
+	redisutil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/redis-util"
+)
+
+const (
+	testKey   = "06f14f72-dfba-49ca-aa4e-d85b532ca0b7"
+	testRoute = "/api/v1/transactions"
+)
+
+// setupRouter creates a router with the idempotency middleware backed by an in-memory store.
+// The handler responds with the given status code and counts how many times it is called.
+func setupRouter(t *testing.T, idemStore store.IdempotencyStore, status int, calls *int) *gin.Engine {
+	t.Setenv("IDEMPOTENCY_ENABLED", "TRUE")
+	t.Setenv("IDEMPOTENCY_KEY_HEADER", "Idempotency-Key")
+	t.Setenv("IDEMPOTENCY_PREFIX", "idempotency_cache:")
+	t.Setenv("IDEMPOTENCY_TTL_HOURS", "24")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST(testRoute, idempotency.Enforce(idemStore), func(c *gin.Context) {
+		*calls++
+		c.Header("Location", "/api/v1/transactions/1")
+		c.Data(status, "application/json", []byte(`{"id":1}`))
+	})
+
+	return router
+}
+
+// sendRequest sends a POST request with the given idempotency key and body and records the response.
+func sendRequest(router *gin.Engine, key string, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", testRoute, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestEnforce_ReplaysStoredResponse(t *testing.T) {
+	calls := 0
+	router := setupRouter(t, store.NewMemoryStore(), http.StatusCreated, &calls)
+
+	first := sendRequest(router, testKey, `{"amount":100,"type":"payment"}`)
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get("Idempotent-Replayed"))
+
+	// A retry with the same key and an equivalent body replays the original response
+	second := sendRequest(router, testKey, `{"type":"payment","amount":100}`)
+	assert.Equal(t, http.StatusCreated, second.Code)
+	assert.Equal(t, "true", second.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, "/api/v1/transactions/1", second.Header().Get("Location"))
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, 1, calls)
+}
+
+func TestEnforce_RejectsDifferentRequestWithSameKey(t *testing.T) {
+	calls := 0
+	router := setupRouter(t, store.NewMemoryStore(), http.StatusCreated, &calls)
+
+	sendRequest(router, testKey, `{"amount":100}`)
+	w := sendRequest(router, testKey, `{"amount":200}`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "body")
+	assert.Equal(t, 1, calls)
+}
+
+func TestEnforce_RejectsSameKeyForDifferentPathParameter(t *testing.T) {
+	calls := 0
+	setupRouter(t, store.NewMemoryStore(), http.StatusOK, &calls) // Sets the environment of the middleware
+
+	router := gin.New()
+	router.POST("/api/v1/transactions/:id/submit", idempotency.Enforce(store.NewMemoryStore()), func(c *gin.Context) {
+		calls++
+		c.Data(http.StatusOK, "application/json", []byte(`{"id":"`+c.Param("id")+`"}`))
+	})
+
+	submit := func(id string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/v1/transactions/"+id+"/submit", nil)
+		req.Header.Set("Idempotency-Key", testKey)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, submit("A").Code)
+
+	// The same key and body against another transaction is a different request, not a retry
+	w := submit("B")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "path")
+	assert.NotContains(t, w.Body.String(), `"id":"A"`)
+	assert.NotContains(t, w.Body.String(), "/transactions/A/")
+	assert.Equal(t, 1, calls)
+
+	// A retry against the first transaction still replays its response
+	w = submit("A")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, 1, calls)
+}
+
+func TestEnforce_ProcessesKeyWithLeftoverIncompleteRecord(t *testing.T) {
+	calls := 0
+	idemStore := store.NewMemoryStore()
+	router := setupRouter(t, idemStore, http.StatusCreated, &calls)
+
+	// A claim without a response, left behind by a request that crashed before releasing the key
+	_, err := idemStore.Complete(context.Background(), entity.IdempotencyCache{
+		Scope:     "global",
+		Key:       testKey,
+		ExpiredAt: time.Now().Add(time.Hour),
+	})
+	assert.NoError(t, err)
+
+	w := sendRequest(router, testKey, `{"amount":100}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, 1, calls)
+
+	// The response of the request replaces the leftover record and is replayed on a retry
+	w = sendRequest(router, testKey, `{"amount":100}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, 1, calls)
+}
+
+func TestEnforce_RequiresKey(t *testing.T) {
+	calls := 0
+	router := setupRouter(t, store.NewMemoryStore(), http.StatusCreated, &calls)
+
+	w := sendRequest(router, "", `{"amount":100}`)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, 0, calls)
+}
+
+func TestEnforce_StoresClientErrors(t *testing.T) {
+	calls := 0
+	router := setupRouter(t, store.NewMemoryStore(), http.StatusBadRequest, &calls)
+
+	sendRequest(router, testKey, `{"amount":100}`)
+	w := sendRequest(router, testKey, `{"amount":100}`)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, 1, calls)
+}
+
+func TestEnforce_ReleasesKeyOnServerError(t *testing.T) {
+	calls := 0
+	router := setupRouter(t, store.NewMemoryStore(), http.StatusInternalServerError, &calls)
+
+	sendRequest(router, testKey, `{"amount":100}`)
+	w := sendRequest(router, testKey, `{"amount":100}`)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Empty(t, w.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, 2, calls)
+}
+
+func TestEnforce_RejectsConcurrentRequest(t *testing.T) {
+	calls := 0
+	idemStore := store.NewMemoryStore()
+	router := setupRouter(t, idemStore, http.StatusCreated, &calls)
+
+	// Simulate another request holding the reservation of the key
+	reservation, err := idemStore.Reserve(context.Background(), "global", testKey, 30*time.Second)
+	assert.NoError(t, err)
+	assert.True(t, reservation.Acquired)
+
+	w := sendRequest(router, testKey, `{"amount":100}`)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	assert.Equal(t, 0, calls)
+
+	// Once the reservation is released, the request is processed
+	assert.NoError(t, idemStore.Release(context.Background(), "global", testKey, reservation.Token))
+	w = sendRequest(router, testKey, `{"amount":100}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, 1, calls)
+}
+
+func TestEnforce_StopsWaitingWhenClientDisconnects(t *testing.T) {
+	calls := 0
+	idemStore := store.NewMemoryStore()
+	router := setupRouter(t, idemStore, http.StatusCreated, &calls)
+	t.Setenv("IDEMPOTENCY_LOCK_WAIT_SECONDS", "5")
+
+	// Simulate another request holding the reservation of the key
+	reservation, err := idemStore.Reserve(context.Background(), "global", testKey, 30*time.Second)
+	assert.NoError(t, err)
+	assert.True(t, reservation.Acquired)
+
+	// The client disconnects while the request waits for the first result
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, "POST", testRoute, bytes.NewBufferString(`{"amount":100}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", testKey)
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Less(t, time.Since(start), 2*time.Second)
+	assert.NotEqual(t, http.StatusCreated, w.Code)
+	assert.Equal(t, 0, calls)
+}
+
+func TestEnforce_SupportsPatch(t *testing.T) {
+	t.Setenv("IDEMPOTENCY_ENABLED", "TRUE")
+	t.Setenv("IDEMPOTENCY_KEY_HEADER", "Idempotency-Key")
+	t.Setenv("IDEMPOTENCY_PREFIX", "idempotency_cache:")
+
+	calls := 0
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.PATCH("/api/v1/consumers/:id", idempotency.Enforce(store.NewMemoryStore()), func(c *gin.Context) {
+		calls++
+		c.Data(http.StatusOK, "application/json", []byte(`{"status":"inactive"}`))
+	})
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("PATCH", "/api/v1/consumers/1?status=inactive", nil)
+		req.Header.Set("Idempotency-Key", testKey)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	assert.Equal(t, 1, calls)
+}
+
+func TestEnforce_SafeMethodPassthrough(t *testing.T) {
+	t.Setenv("IDEMPOTENCY_ENABLED", "TRUE")
+	t.Setenv("IDEMPOTENCY_KEY_HEADER", "Idempotency-Key")
+	t.Setenv("IDEMPOTENCY_PREFIX", "idempotency_cache:")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	strict := router.Group("/strict", idempotency.Enforce(store.NewMemoryStore()))
+	strict.GET("", func(c *gin.Context) { c.Status(http.StatusOK) })
+	mixed := router.Group("/mixed", idempotency.Enforce(store.NewMemoryStore(), idempotency.WithSafeMethodPassthrough()))
+	mixed.GET("", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	// Without passthrough, safe methods are rejected
+	req, _ := http.NewRequest("GET", "/strict", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	// With passthrough, safe methods run without an idempotency key
+	req, _ = http.NewRequest("GET", "/mixed", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestEnforce_OptionalKey(t *testing.T) {
+	t.Setenv("IDEMPOTENCY_ENABLED", "TRUE")
+	t.Setenv("IDEMPOTENCY_KEY_HEADER", "Idempotency-Key")
+	t.Setenv("IDEMPOTENCY_PREFIX", "idempotency_cache:")
+
+	calls := 0
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST(testRoute, idempotency.Enforce(store.NewMemoryStore(), idempotency.WithOptionalKey()), func(c *gin.Context) {
+		calls++
+		c.Status(http.StatusCreated)
+	})
+
+	// Requests without a key are processed every time
+	sendRequest(router, "", `{"amount":100}`)
+	w := sendRequest(router, "", `{"amount":100}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, 2, calls)
+
+	// Requests with a key are still deduplicated
+	sendRequest(router, testKey, `{"amount":100}`)
+	sendRequest(router, testKey, `{"amount":100}`)
+	assert.Equal(t, 3, calls)
+}
+
+func TestEnforce_IsolatesScopes(t *testing.T) {
+	calls := 0
+	setupRouter(t, store.NewMemoryStore(), http.StatusCreated, &calls) // Sets the environment of the middleware
+
+	router := gin.New()
+	router.POST(testRoute, idempotency.Enforce(store.NewMemoryStore(), idempotency.WithScope(idempotency.ScopeFromHeader("X-Client-ID"))), func(c *gin.Context) {
+		calls++
+		c.Data(http.StatusCreated, "application/json", []byte(`{"client":"`+c.GetHeader("X-Client-ID")+`"}`))
+	})
+
+	send := func(client string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", testRoute, bytes.NewBufferString(`{"amount":100}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", testKey)
+		req.Header.Set("X-Client-ID", client)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Two clients that pick the same key each get their own response
+	assert.Equal(t, `{"client":"a"}`, send("a").Body.String())
+	assert.Equal(t, `{"client":"b"}`, send("b").Body.String())
+	assert.Equal(t, 2, calls)
+
+	// Scopes and keys containing the separator never share a record
+	assert.Equal(t, `{"client":"a:b"}`, send("a:b").Body.String())
+	assert.Equal(t, 3, calls)
+
+	// Each client still gets its own response replayed
+	w := send("a")
+	assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, `{"client":"a"}`, w.Body.String())
+	assert.Equal(t, 3, calls)
+}
+
+func TestEnforce_RejectsInvalidKey(t *testing.T) {
+	calls := 0
+	router := setupRouter(t, store.NewMemoryStore(), http.StatusCreated, &calls)
+
+	w := sendRequest(router, "not-a-uuid", `{"amount":100}`)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "UUIDv4")
+	assert.Equal(t, 0, calls)
+}
+
+func TestEnforce_NormalizesKeyCase(t *testing.T) {
+	t.Setenv("IDEMPOTENCY_KEY_FORMAT", "ULID")
+	calls := 0
+	router := setupRouter(t, store.NewMemoryStore(), http.StatusCreated, &calls)
+
+	w := sendRequest(router, "01ARZ3NDEKTSV4RRFFQ69G5FAV", `{"amount":100}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	// The lowercase spelling of the same ULID is a retry of the same request
+	w = sendRequest(router, "01arz3ndektsv4rrffq69g5fav", `{"amount":100}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, 1, calls)
+
+	// A lowercase key is stored under its canonical form too
+	w = sendRequest(router, "01bx5zzkbkactav9wevgemmvrz", `{"amount":100}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = sendRequest(router, "01BX5ZZKBKACTAV9WEVGEMMVRZ", `{"amount":100}`)
+	assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, 2, calls)
+}
+
+// unavailableStore is an idempotency store whose lookups fail with the given error.
+type unavailableStore struct {
+	*store.MemoryStore
+	err error
+}
+
+func (s *unavailableStore) Get(ctx context.Context, scope string, key string) (*entity.IdempotencyCache, error) {
+	return nil, s.err
+}
+
+func TestEnforce_ReportsRedisOutages(t *testing.T) {
+	cases := map[int]error{
+		http.StatusServiceUnavailable:  fmt.Errorf("get: %w", breakerutil.ErrOpen),
+		http.StatusGatewayTimeout:      fmt.Errorf("get: %w", redisutil.ErrTimeout),
+		http.StatusInternalServerError: errors.New("unexpected reply"),
+	}
+
+	for status, err := range cases {
+		calls := 0
+		router := setupRouter(t, &unavailableStore{MemoryStore: store.NewMemoryStore(), err: err}, http.StatusCreated, &calls)
+
+		w := sendRequest(router, testKey, `{"amount":100}`)
+
+		assert.Equal(t, status, w.Code)
+		assert.Equal(t, 0, calls)
+	}
+}
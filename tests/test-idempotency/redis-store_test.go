@@ -0,0 +1,166 @@
+package test_idempotency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-idempotency-with-redis/config/cache"
+	"github.com/yoanesber/go-idempotency-with-redis/config/database"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/entity"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/repository"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/store"
+)
+
+// memoryCacheRepository keeps the idempotency records of the Postgres store in memory and counts the lookups.
+type memoryCacheRepository struct {
+	repository.IdempotencyCacheRepository
+	records map[string]entity.IdempotencyCache
+	lookups int
+}
+
+func newMemoryCacheRepository(records ...entity.IdempotencyCache) *memoryCacheRepository {
+	r := &memoryCacheRepository{records: make(map[string]entity.IdempotencyCache)}
+	for _, record := range records {
+		r.records[record.Scope+"\x00"+record.Key] = record
+	}
+	return r
+}
+
+func (r *memoryCacheRepository) GetIdempotencyCacheByKey(tx *gorm.DB, scope string, key string) (entity.IdempotencyCache, error) {
+	r.lookups++
+	record, ok := r.records[scope+"\x00"+key]
+	if !ok {
+		return entity.IdempotencyCache{}, gorm.ErrRecordNotFound
+	}
+	return record, nil
+}
+
+func (r *memoryCacheRepository) UpdateIdempotencyCache(tx *gorm.DB, record entity.IdempotencyCache) (entity.IdempotencyCache, error) {
+	r.records[record.Scope+"\x00"+record.Key] = record
+	return record, nil
+}
+
+// useMiniredis replaces the Redis client with one connected to an in-memory Redis server.
+func useMiniredis(t *testing.T) *miniredis.Miniredis {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+
+	previous := cache.RedisClient
+	cache.RedisClient = client
+	t.Cleanup(func() {
+		cache.RedisClient = previous
+		client.Close()
+	})
+
+	return server
+}
+
+// useMockedPostgres replaces the database connection with a mocked one, whose transactions are expected by the test.
+func useMockedPostgres(t *testing.T) sqlmock.Sqlmock {
+	conn, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: conn}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	database.SetPostgres(db)
+	t.Cleanup(func() {
+		database.SetPostgres(nil)
+		conn.Close()
+	})
+
+	return mock
+}
+
+// completedRecord returns a record of the key with a stored response, which expires after the given lifetime.
+func completedRecord(key string, lifetime time.Duration) entity.IdempotencyCache {
+	return entity.IdempotencyCache{
+		Scope:           "global",
+		Key:             key,
+		StatusCode:      201,
+		ResponsePayload: `{"message":"Transaction created successfully"}`,
+		CreatedAt:       time.Now(),
+		ExpiredAt:       time.Now().Add(lifetime),
+	}
+}
+
+func TestRedisStore_ReadsThroughToPostgresOnRedisMiss(t *testing.T) {
+	t.Setenv("IDEMPOTENCY_PREFIX", "idempotency_cache:")
+	server := useMiniredis(t)
+	useMockedPostgres(t)
+
+	inFlight := completedRecord("in-flight", time.Hour)
+	inFlight.StatusCode, inFlight.ResponsePayload = 0, ""
+	repo := newMemoryCacheRepository(completedRecord("completed", 2*time.Hour), inFlight, completedRecord("expired", -time.Minute))
+	idemStore := store.NewRedisStore(store.NewPostgresStore(repo, nil), store.FailClosed)
+	ctx := context.Background()
+
+	// Redis was flushed, so the record is read from Postgres and Redis is rehydrated with its remaining lifetime
+	record, err := idemStore.Get(ctx, "global", "completed")
+	assert.NoError(t, err)
+	assert.NotNil(t, record)
+	assert.Equal(t, 201, record.StatusCode)
+	assert.Equal(t, 1, repo.lookups)
+
+	redisKey := store.GetRedisKey("global", "completed")
+	assert.True(t, server.Exists(redisKey))
+	assert.InDelta(t, (2 * time.Hour).Seconds(), server.TTL(redisKey).Seconds(), 5)
+
+	// The next lookup is served by Redis without reaching Postgres
+	record, err = idemStore.Get(ctx, "global", "completed")
+	assert.NoError(t, err)
+	assert.Equal(t, 201, record.StatusCode)
+	assert.Equal(t, 1, repo.lookups)
+
+	// A record without a response is still being processed, so it is returned but not rehydrated
+	record, err = idemStore.Get(ctx, "global", "in-flight")
+	assert.NoError(t, err)
+	assert.NotNil(t, record)
+	assert.False(t, server.Exists(store.GetRedisKey("global", "in-flight")))
+
+	// An expired record is treated as never seen
+	record, err = idemStore.Get(ctx, "global", "expired")
+	assert.NoError(t, err)
+	assert.Nil(t, record)
+	assert.False(t, server.Exists(store.GetRedisKey("global", "expired")))
+}
+
+func TestRedisStore_TTLFollowsExpiredAt(t *testing.T) {
+	t.Setenv("IDEMPOTENCY_PREFIX", "idempotency_cache:")
+	server := useMiniredis(t)
+	mock := useMockedPostgres(t)
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	// The key was claimed half an hour before its expiry, while the request was processed
+	claimed := completedRecord("claimed", 30*time.Minute)
+	claimed.StatusCode, claimed.ResponsePayload = 0, ""
+	repo := newMemoryCacheRepository(claimed)
+	idemStore := store.NewRedisStore(store.NewPostgresStore(repo, nil), store.FailClosed)
+	ctx := context.Background()
+
+	// Storing the response keeps the expiry of the claimed record, so the lifetime of the key is not extended
+	saved, err := idemStore.Complete(ctx, completedRecord("claimed", 24*time.Hour))
+	assert.NoError(t, err)
+	assert.True(t, saved.ExpiredAt.Equal(claimed.ExpiredAt))
+	assert.True(t, repo.records["global\x00claimed"].ExpiredAt.Equal(claimed.ExpiredAt))
+	assert.InDelta(t, (30 * time.Minute).Seconds(), server.TTL(store.GetRedisKey("global", "claimed")).Seconds(), 5)
+
+	// A key that was not claimed expires in Redis at the expiration time of its record
+	saved, err = idemStore.Complete(ctx, completedRecord("unclaimed", 2*time.Hour))
+	assert.NoError(t, err)
+	assert.InDelta(t, time.Until(saved.ExpiredAt).Seconds(), server.TTL(store.GetRedisKey("global", "unclaimed")).Seconds(), 5)
+	assert.InDelta(t, (2 * time.Hour).Seconds(), server.TTL(store.GetRedisKey("global", "unclaimed")).Seconds(), 5)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,37 @@
+package test_idempotency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yoanesber/go-idempotency-with-redis/internal/entity"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/store"
+)
+
+func TestStore_SeparatorDoesNotMergeScopes(t *testing.T) {
+	t.Setenv("IDEMPOTENCY_PREFIX", "idempotency_cache:")
+
+	// Opaque keys and scopes may both contain the separator
+	assert.NotEqual(t, store.GetRedisKey("a:b", "c"), store.GetRedisKey("a", "b:c"))
+	assert.Equal(t, "idempotency_cache:6:global:c", store.GetRedisKey("global", "c"))
+
+	ctx := context.Background()
+	idemStore := store.NewMemoryStore()
+	_, err := idemStore.Complete(ctx, entity.IdempotencyCache{Scope: "a:b", Key: "c", StatusCode: 201, ExpiredAt: time.Now().Add(time.Hour)})
+	assert.NoError(t, err)
+
+	record, err := idemStore.Get(ctx, "a", "b:c")
+	assert.NoError(t, err)
+	assert.Nil(t, record)
+
+	reservation, err := idemStore.Reserve(ctx, "a:b", "c", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, reservation.Acquired)
+
+	reservation, err = idemStore.Reserve(ctx, "a", "b:c", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, reservation.Acquired)
+}
@@ -0,0 +1,183 @@
+package test_outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-idempotency-with-redis/config/database"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/entity"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/publisher"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/repository"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/worker"
+)
+
+// stubOutboxRepository hands out its pending messages once and sends every updated message to a channel.
+type stubOutboxRepository struct {
+	repository.OutboxMessageRepository
+	pending []entity.OutboxMessage
+	updated chan entity.OutboxMessage
+}
+
+func (r *stubOutboxRepository) ClaimPendingOutboxMessages(tx *gorm.DB, now time.Time, limit int) ([]entity.OutboxMessage, error) {
+	messages := r.pending
+	r.pending = nil
+	return messages, nil
+}
+
+func (r *stubOutboxRepository) UpdateOutboxMessage(tx *gorm.DB, m entity.OutboxMessage) error {
+	r.updated <- m
+	return nil
+}
+
+// stubPublisher fails to publish the messages whose ID is in failing, and records the others.
+type stubPublisher struct {
+	failing   map[string]bool
+	published []string
+}
+
+func (p *stubPublisher) Publish(ctx context.Context, msg publisher.Message) error {
+	if p.failing[msg.ID] {
+		return errors.New("broker unavailable")
+	}
+
+	p.published = append(p.published, msg.ID)
+	return nil
+}
+
+// useMockedPostgres replaces the database connection with a mocked one, whose statements are expected by the test.
+func useMockedPostgres(t *testing.T) sqlmock.Sqlmock {
+	conn, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: conn}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	database.SetPostgres(db)
+	t.Cleanup(func() {
+		database.SetPostgres(nil)
+		conn.Close()
+	})
+
+	return mock
+}
+
+// runRelayBatch starts a relay with the given repository and publisher, waits until the given number of messages
+// has been updated, stops the relay and returns the updated messages by ID.
+func runRelayBatch(t *testing.T, repo repository.OutboxMessageRepository, pub publisher.Publisher, updated chan entity.OutboxMessage, count int) map[string]entity.OutboxMessage {
+	t.Setenv("OUTBOX_POLL_INTERVAL_MS", "60000")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	relay := worker.NewOutboxRelay(repo, pub)
+	relay.Start(ctx)
+
+	messages := make(map[string]entity.OutboxMessage)
+	for len(messages) < count {
+		select {
+		case m := <-updated:
+			messages[m.ID] = m
+		case <-time.After(5 * time.Second):
+			t.Fatalf("relay updated %d of %d messages", len(messages), count)
+		}
+	}
+
+	cancel()
+	relay.Wait()
+	return messages
+}
+
+func TestOutboxRelay_RetriesWithExponentialBackoff(t *testing.T) {
+	t.Setenv("OUTBOX_BATCH_SIZE", "100")
+	t.Setenv("OUTBOX_MAX_ATTEMPTS", "20")
+
+	mock := useMockedPostgres(t)
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	repo := &stubOutboxRepository{
+		pending: []entity.OutboxMessage{
+			{ID: "first-attempt", Status: entity.OutboxStatusPending},
+			{ID: "fourth-attempt", Status: entity.OutboxStatusPending, Attempts: 3},
+			{ID: "capped", Status: entity.OutboxStatusPending, Attempts: 15},
+			{ID: "last-attempt", Status: entity.OutboxStatusPending, Attempts: 19},
+			{ID: "delivered", Status: entity.OutboxStatusPending, Attempts: 2, LastError: "broker unavailable"},
+		},
+		updated: make(chan entity.OutboxMessage, 5),
+	}
+	pub := &stubPublisher{failing: map[string]bool{"first-attempt": true, "fourth-attempt": true, "capped": true, "last-attempt": true}}
+
+	start := time.Now()
+	messages := runRelayBatch(t, repo, pub, repo.updated, 5)
+
+	// The delay before the next attempt doubles on every failure, starting at one second and capped at five minutes
+	delays := map[string]time.Duration{
+		"first-attempt":  time.Second,
+		"fourth-attempt": 8 * time.Second,
+		"capped":         5 * time.Minute,
+	}
+	for id, delay := range delays {
+		m := messages[id]
+		assert.Equal(t, entity.OutboxStatusPending, m.Status, id)
+		assert.Equal(t, "broker unavailable", m.LastError, id)
+		assert.WithinDuration(t, start.Add(delay), m.NextAttemptAt, time.Second, id)
+		assert.Nil(t, m.DeliveredAt, id)
+	}
+	assert.Equal(t, 1, messages["first-attempt"].Attempts)
+	assert.Equal(t, 4, messages["fourth-attempt"].Attempts)
+
+	// A message is given up on once it reaches the maximum number of attempts
+	assert.Equal(t, entity.OutboxStatusFailed, messages["last-attempt"].Status)
+	assert.Equal(t, 20, messages["last-attempt"].Attempts)
+
+	// A retried message that is published is marked as delivered and its last error is cleared
+	assert.Equal(t, entity.OutboxStatusDelivered, messages["delivered"].Status)
+	assert.Equal(t, 3, messages["delivered"].Attempts)
+	assert.Empty(t, messages["delivered"].LastError)
+	assert.NotNil(t, messages["delivered"].DeliveredAt)
+	assert.Equal(t, []string{"delivered"}, pub.published)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// recordingOutboxRepository sends every updated message to a channel, after the update was run by the real repository.
+type recordingOutboxRepository struct {
+	repository.OutboxMessageRepository
+	updated chan entity.OutboxMessage
+}
+
+func (r *recordingOutboxRepository) UpdateOutboxMessage(tx *gorm.DB, m entity.OutboxMessage) error {
+	err := r.OutboxMessageRepository.UpdateOutboxMessage(tx, m)
+	r.updated <- m
+	return err
+}
+
+func TestOutboxRelay_ClaimsMessagesWithSkipLocked(t *testing.T) {
+	t.Setenv("OUTBOX_BATCH_SIZE", "2")
+	mock := useMockedPostgres(t)
+
+	// Messages are claimed with FOR UPDATE SKIP LOCKED, so a message claimed by another relay is skipped instead of waited for,
+	// and the row locks are held until the outcome of the delivery is committed in the same database transaction
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "outbox" WHERE status = \$1 AND next_attempt_at <= \$2 ORDER BY next_attempt_at ASC LIMIT \$3 FOR UPDATE SKIP LOCKED`).
+		WithArgs(entity.OutboxStatusPending, sqlmock.AnyArg(), 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "topic", "aggregate_id", "payload", "status", "attempts"}).
+			AddRow("a9f0c3c4-8a84-4d8c-9c4f-0c6a2b1f3b11", "payment-event", "147735b9-eff7-469d-ac85-3b8108825ce4", "{}", entity.OutboxStatusPending, 0))
+	mock.ExpectExec(`UPDATE "outbox" SET .* WHERE id = \$\d+`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	repo := &recordingOutboxRepository{OutboxMessageRepository: repository.NewOutboxMessageRepository(), updated: make(chan entity.OutboxMessage, 1)}
+	pub := &stubPublisher{}
+
+	messages := runRelayBatch(t, repo, pub, repo.updated, 1)
+
+	assert.Equal(t, entity.OutboxStatusDelivered, messages["a9f0c3c4-8a84-4d8c-9c4f-0c6a2b1f3b11"].Status)
+	assert.Equal(t, []string{"a9f0c3c4-8a84-4d8c-9c4f-0c6a2b1f3b11"}, pub.published)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,97 @@
+package test_outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yoanesber/go-idempotency-with-redis/config/cache"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/publisher"
+)
+
+// errCaptured stops a command before it is sent, once it has been captured.
+var errCaptured = errors.New("command captured")
+
+// captureHook records the arguments of each command and stops it before it reaches Redis.
+type captureHook struct {
+	args [][]interface{}
+}
+
+func (h *captureHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	h.args = append(h.args, cmd.Args())
+	return ctx, errCaptured
+}
+
+func (h *captureHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	return nil
+}
+
+func (h *captureHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return ctx, errCaptured
+}
+
+func (h *captureHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return nil
+}
+
+// useCaptureClient replaces the Redis client with one whose commands are captured instead of sent.
+func useCaptureClient(t *testing.T) *captureHook {
+	hook := &captureHook{}
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:0"})
+	client.AddHook(hook)
+
+	previous := cache.RedisClient
+	cache.RedisClient = client
+	t.Cleanup(func() {
+		cache.RedisClient = previous
+		client.Close()
+	})
+
+	return hook
+}
+
+func TestRedisStreamPublisher_AddsMessageToTopicStream(t *testing.T) {
+	hook := useCaptureClient(t)
+
+	p := publisher.NewRedisStreamPublisher(1000)
+	err := p.Publish(context.Background(), publisher.Message{
+		ID:                    "0b9d5a57-6d3f-4f57-a1b8-7c1de9a6c2f0",
+		Topic:                 "payment-event",
+		AggregateID:           "147735b9-eff7-469d-ac85-3b8108825ce4",
+		IdempotencyCacheScope: "a3f1c2d4-5b6e-4f7a-8b9c-0d1e2f3a4b5c",
+		IdempotencyCacheKey:   "2c9b7f4e-1d3a-4c5b-8e6f-7a8b9c0d1e2f",
+		Payload:               `{"status":"pending"}`,
+		CreatedAt:             time.Date(2025, 6, 18, 16, 20, 2, 0, time.UTC),
+	})
+	assert.ErrorIs(t, err, errCaptured)
+
+	// XADD payment-event MAXLEN ~ 1000 * field value ...
+	assert.Len(t, hook.args, 1)
+	args := hook.args[0]
+	assert.Equal(t, []interface{}{"xadd", "payment-event", "maxlen", "~", int64(1000), "*"}, args[:6])
+
+	fields := map[interface{}]interface{}{}
+	for i := 6; i+1 < len(args); i += 2 {
+		fields[args[i]] = args[i+1]
+	}
+	assert.Equal(t, "0b9d5a57-6d3f-4f57-a1b8-7c1de9a6c2f0", fields["id"])
+	assert.Equal(t, "2c9b7f4e-1d3a-4c5b-8e6f-7a8b9c0d1e2f", fields["idempotencyCacheKey"])
+	assert.Equal(t, "a3f1c2d4-5b6e-4f7a-8b9c-0d1e2f3a4b5c", fields["idempotencyCacheScope"])
+	assert.Equal(t, `{"status":"pending"}`, fields["payload"])
+	assert.Equal(t, "2025-06-18T16:20:02Z", fields["createdAt"])
+}
+
+func TestRedisStreamPublisher_DoesNotTrimWithoutMaxLen(t *testing.T) {
+	hook := useCaptureClient(t)
+
+	p := publisher.NewRedisStreamPublisher(0)
+	err := p.Publish(context.Background(), publisher.Message{ID: "1", Topic: "withdrawal-event"})
+	assert.ErrorIs(t, err, errCaptured)
+
+	assert.Len(t, hook.args, 1)
+	assert.Equal(t, []interface{}{"xadd", "withdrawal-event", "*"}, hook.args[0][:3])
+}
@@ -0,0 +1,147 @@
+package test_outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-idempotency-with-redis/internal/entity"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/processor"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/repository"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/service"
+	metacontext "github.com/yoanesber/go-idempotency-with-redis/pkg/context-data/meta-context"
+)
+
+const (
+	testConsumerID    = "a1b9d37e-2e7d-42b2-9d3e-7b492162905d"
+	testTransactionID = "147735b9-eff7-469d-ac85-3b8108825ce4"
+	testKey           = "06f14f72-dfba-49ca-aa4e-d85b532ca0b7"
+)
+
+// recordingTransactionRepository records the database transaction the transaction is created in.
+type recordingTransactionRepository struct {
+	repository.TransactionRepository
+	tx      *gorm.DB
+	created entity.Transaction
+}
+
+func (r *recordingTransactionRepository) CreateTransaction(tx *gorm.DB, t entity.Transaction) (entity.Transaction, error) {
+	t.ID = testTransactionID
+	r.tx, r.created = tx, t
+	return t, nil
+}
+
+func (r *recordingTransactionRepository) GetTransactionByID(tx *gorm.DB, id string) (entity.Transaction, error) {
+	return r.created, nil
+}
+
+// recordingTransactionEventRepository records the database transaction the history is written in.
+type recordingTransactionEventRepository struct {
+	repository.TransactionEventRepository
+	tx *gorm.DB
+}
+
+func (r *recordingTransactionEventRepository) CreateTransactionEvent(tx *gorm.DB, e entity.TransactionEvent) (entity.TransactionEvent, error) {
+	r.tx = tx
+	return e, nil
+}
+
+// recordingOutboxMessageRepository records the database transaction the outbox message is written in.
+type recordingOutboxMessageRepository struct {
+	repository.OutboxMessageRepository
+	tx       *gorm.DB
+	messages []entity.OutboxMessage
+}
+
+func (r *recordingOutboxMessageRepository) CreateOutboxMessage(tx *gorm.DB, m entity.OutboxMessage) (entity.OutboxMessage, error) {
+	r.tx = tx
+	r.messages = append(r.messages, m)
+	return m, nil
+}
+
+// unconfiguredProcessorClient refuses every submission, as the processor client does without PROCESSOR_URL.
+type unconfiguredProcessorClient struct{}
+
+func (unconfiguredProcessorClient) Submit(ctx context.Context, t entity.Transaction) (processor.Reply, error) {
+	return processor.Reply{}, processor.ErrProcessorNotConfigured
+}
+
+// createTransaction creates a payment through the transaction service with the given repositories and an idempotency key in the context.
+func createTransaction(repo repository.TransactionRepository, eventRepo repository.TransactionEventRepository, outboxRepo repository.OutboxMessageRepository) (entity.Transaction, error) {
+	ctx := metacontext.InjectIdemCompetencyMeta(context.Background(), metacontext.IdemCompetencyMeta{
+		Scope:     testConsumerID,
+		Key:       testKey,
+		BodyHash:  "hash",
+		ExpiredAt: time.Now().Add(time.Hour),
+	})
+
+	svc := service.NewTransactionService(repo, eventRepo, outboxRepo, unconfiguredProcessorClient{})
+	return svc.CreateTransaction(ctx, entity.Transaction{Type: entity.TransactionTypePayment, Amount: 150000, ConsumerID: testConsumerID})
+}
+
+// expectActiveConsumer expects the lookup of the consumer of the transaction, which is active.
+func expectActiveConsumer(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT \* FROM "consumers"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow(testConsumerID, entity.ConsumerStatusActive))
+}
+
+func TestCreateTransaction_WritesOutboxInSameTransaction(t *testing.T) {
+	mock := useMockedPostgres(t)
+
+	// The transaction, its history, the outbox message and the idempotency key are committed together
+	mock.ExpectBegin()
+	expectActiveConsumer(mock)
+	mock.ExpectQuery(`SELECT \* FROM "idempotency_cache"`).
+		WillReturnRows(sqlmock.NewRows([]string{"scope", "key"}))
+	mock.ExpectQuery(`INSERT INTO "idempotency_cache"`).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at"}).AddRow(time.Now(), time.Now()))
+	mock.ExpectCommit()
+
+	repo := &recordingTransactionRepository{}
+	eventRepo := &recordingTransactionEventRepository{}
+	outboxRepo := &recordingOutboxMessageRepository{}
+
+	created, err := createTransaction(repo, eventRepo, outboxRepo)
+	assert.NoError(t, err)
+	assert.Equal(t, entity.TransactionStatusPending, created.Status)
+
+	assert.NotNil(t, repo.tx)
+	assert.Same(t, repo.tx, eventRepo.tx)
+	assert.Same(t, repo.tx, outboxRepo.tx)
+
+	assert.Len(t, outboxRepo.messages, 1)
+	assert.Equal(t, "payment-event", outboxRepo.messages[0].Topic)
+	assert.Equal(t, testTransactionID, outboxRepo.messages[0].AggregateID)
+	assert.Equal(t, testKey, outboxRepo.messages[0].IdempotencyCacheKey)
+	assert.Equal(t, entity.OutboxStatusPending, outboxRepo.messages[0].Status)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateTransaction_RollsBackOutboxWithTransaction(t *testing.T) {
+	mock := useMockedPostgres(t)
+
+	// The idempotency key cannot be claimed after the outbox message was written, so the whole database transaction is rolled back
+	mock.ExpectBegin()
+	expectActiveConsumer(mock)
+	mock.ExpectQuery(`SELECT \* FROM "idempotency_cache"`).
+		WillReturnError(errors.New("connection reset by peer"))
+	mock.ExpectRollback()
+
+	repo := &recordingTransactionRepository{}
+	eventRepo := &recordingTransactionEventRepository{}
+	outboxRepo := &recordingOutboxMessageRepository{}
+
+	_, err := createTransaction(repo, eventRepo, outboxRepo)
+	assert.Error(t, err)
+
+	// The outbox message was written in the rolled back database transaction, so it is discarded with the transaction
+	assert.Len(t, outboxRepo.messages, 1)
+	assert.NotNil(t, repo.tx)
+	assert.Same(t, repo.tx, outboxRepo.tx)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
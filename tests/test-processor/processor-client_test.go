@@ -0,0 +1,212 @@
+package test_processor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yoanesber/go-idempotency-with-redis/internal/entity"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/processor"
+)
+
+var transaction = entity.Transaction{
+	ID:                    "147735b9-eff7-469d-ac85-3b8108825ce4",
+	IdempotencyCacheScope: "a3f1c2d4-5b6e-4f7a-8b9c-0d1e2f3a4b5c",
+	IdempotencyCacheKey:   "2c9b7f4e-1d3a-4c5b-8e6f-7a8b9c0d1e2f",
+	Type:                  entity.TransactionTypePayment,
+	Amount:                100,
+	Status:                entity.TransactionStatusPending,
+}
+
+// newClient creates a processor client for the given stand-in processor, with short backoff so retries are fast.
+func newClient(t *testing.T, server *httptest.Server, maxAttempts string) *processor.HTTPProcessorClient {
+	t.Setenv("PROCESSOR_URL", server.URL)
+	t.Setenv("PROCESSOR_MAX_ATTEMPTS", maxAttempts)
+	t.Setenv("PROCESSOR_BACKOFF_BASE_MS", "1")
+	t.Setenv("PROCESSOR_BACKOFF_MAX_MS", "5")
+	return processor.NewProcessorClient()
+}
+
+// reply writes a reply from the stand-in processor.
+func reply(w http.ResponseWriter, code int, status string, reason string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"status": status, "reason": reason})
+}
+
+func TestProcessorClient_ForwardsIdempotencyKeyAndRetries(t *testing.T) {
+	var attempts atomic.Int32
+	keys := make(chan string, 3)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys <- r.Header.Get("Idempotency-Key")
+
+		var body entity.Transaction
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, transaction.ID, body.ID)
+
+		// Fail twice before accepting the transaction
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		reply(w, http.StatusAccepted, "accepted", "")
+	}))
+	defer server.Close()
+
+	r, err := newClient(t, server, "5").Submit(context.Background(), transaction)
+	assert.NoError(t, err)
+	assert.Equal(t, entity.TransactionStatusProcessing, r.Status)
+	assert.Equal(t, int32(3), attempts.Load())
+
+	// Every attempt carries the same downstream idempotency key, derived from the scope and the key
+	close(keys)
+	for key := range keys {
+		assert.Equal(t, processor.DownstreamKey(transaction), key)
+		assert.NotEqual(t, transaction.IdempotencyCacheKey, key)
+	}
+}
+
+func TestProcessorClient_DownstreamKeyIsUniquePerScope(t *testing.T) {
+	// Two consumers using the same idempotency key get different downstream keys
+	other := transaction
+	other.IdempotencyCacheScope = "b7e2d3c4-6a5f-4e8b-9c0d-1e2f3a4b5c6d"
+	assert.NotEqual(t, processor.DownstreamKey(transaction), processor.DownstreamKey(other))
+
+	// The scope and the key cannot be shifted into each other
+	shifted := transaction
+	shifted.IdempotencyCacheScope = transaction.IdempotencyCacheScope + transaction.IdempotencyCacheKey[:1]
+	shifted.IdempotencyCacheKey = transaction.IdempotencyCacheKey[1:]
+	assert.NotEqual(t, processor.DownstreamKey(transaction), processor.DownstreamKey(shifted))
+
+	assert.Equal(t, processor.DownstreamKey(transaction), processor.DownstreamKey(transaction))
+	assert.Len(t, processor.DownstreamKey(transaction), 64)
+}
+
+func TestProcessorClient_MapsReplies(t *testing.T) {
+	tests := []struct {
+		code   int
+		status string
+		reason string
+		want   processor.Reply
+	}{
+		{http.StatusOK, "completed", "", processor.Reply{Status: entity.TransactionStatusCompleted}},
+		{http.StatusOK, "declined", "insufficient funds", processor.Reply{Status: entity.TransactionStatusFailed, Reason: "insufficient funds"}},
+		{http.StatusAccepted, "processing", "", processor.Reply{Status: entity.TransactionStatusProcessing}},
+		{http.StatusUnprocessableEntity, "", "unsupported currency", processor.Reply{Status: entity.TransactionStatusFailed, Reason: "unsupported currency"}},
+		{http.StatusPaymentRequired, "", "", processor.Reply{Status: entity.TransactionStatusFailed, Reason: "declined by the processor with status 402"}},
+		{http.StatusBadRequest, "declined", "card expired", processor.Reply{Status: entity.TransactionStatusFailed, Reason: "card expired"}},
+	}
+
+	for _, tt := range tests {
+		var attempts atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts.Add(1)
+			reply(w, tt.code, tt.status, tt.reason)
+		}))
+
+		r, err := newClient(t, server, "3").Submit(context.Background(), transaction)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, r)
+
+		// A final reply, including a rejection, is never retried
+		assert.Equal(t, int32(1), attempts.Load())
+		server.Close()
+	}
+}
+
+func TestProcessorClient_DoesNotFailTransactionOnRequestErrors(t *testing.T) {
+	// Bad credentials, a wrong URL or a malformed request say nothing about the transaction itself
+	for _, code := range []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusProxyAuthRequired} {
+		var attempts atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts.Add(1)
+			w.WriteHeader(code)
+		}))
+
+		_, err := newClient(t, server, "3").Submit(context.Background(), transaction)
+		assert.ErrorIs(t, err, processor.ErrProcessorRejected, "status %d", code)
+		assert.Equal(t, int32(1), attempts.Load(), "status %d", code)
+		server.Close()
+	}
+}
+
+func TestProcessorClient_StopsRetryingAfterBudget(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	t.Setenv("PROCESSOR_URL", server.URL)
+	t.Setenv("PROCESSOR_MAX_ATTEMPTS", "1000")
+	t.Setenv("PROCESSOR_BACKOFF_BASE_MS", "10")
+	t.Setenv("PROCESSOR_BACKOFF_MAX_MS", "10")
+	t.Setenv("PROCESSOR_BUDGET_MS", "100")
+	client := processor.NewProcessorClient()
+
+	start := time.Now()
+	_, err := client.Submit(context.Background(), transaction)
+	assert.ErrorIs(t, err, processor.ErrProcessorUnavailable)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Less(t, attempts.Load(), int32(1000))
+}
+
+func TestProcessorClient_ReportsUnavailableAfterLastAttempt(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	_, err := newClient(t, server, "3").Submit(context.Background(), transaction)
+	assert.ErrorIs(t, err, processor.ErrProcessorUnavailable)
+	assert.Equal(t, int32(3), attempts.Load())
+}
+
+func TestProcessorClient_RejectsUnknownStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reply(w, http.StatusOK, "teleported", "")
+	}))
+	defer server.Close()
+
+	_, err := newClient(t, server, "3").Submit(context.Background(), transaction)
+	assert.ErrorIs(t, err, processor.ErrInvalidReply)
+}
+
+func TestProcessorClient_StopsRetryingWhenContextIsCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	t.Setenv("PROCESSOR_URL", server.URL)
+	t.Setenv("PROCESSOR_MAX_ATTEMPTS", "100")
+	t.Setenv("PROCESSOR_BACKOFF_BASE_MS", "1000")
+	client := processor.NewProcessorClient()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := client.Submit(ctx, transaction)
+	assert.ErrorIs(t, err, processor.ErrProcessorUnavailable)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestProcessorClient_RefusesToSubmitWithoutURL(t *testing.T) {
+	t.Setenv("PROCESSOR_URL", "")
+	client := processor.NewProcessorClient()
+
+	_, err := client.Submit(context.Background(), transaction)
+	assert.ErrorIs(t, err, processor.ErrProcessorNotConfigured)
+}
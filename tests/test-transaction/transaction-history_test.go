@@ -0,0 +1,88 @@
+package test_transaction
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-idempotency-with-redis/internal/entity"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/handler"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/service"
+)
+
+// stubTransactionService records the status change it receives and returns a fixed history.
+type stubTransactionService struct {
+	service.TransactionService
+	history []entity.TransactionEvent
+	change  entity.TransactionStatusChange
+}
+
+func (s *stubTransactionService) GetTransactionHistory(id string) ([]entity.TransactionEvent, error) {
+	if s.history == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return s.history, nil
+}
+
+func (s *stubTransactionService) FailTransaction(ctx context.Context, id string, change entity.TransactionStatusChange) (entity.Transaction, error) {
+	s.change = change
+	return entity.Transaction{ID: id, Status: entity.TransactionStatusFailed}, nil
+}
+
+// newRouter creates a router with the history and fail routes of the transaction handler.
+func newRouter(s service.TransactionService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	h := handler.NewTransactionHandler(s)
+
+	r := gin.New()
+	r.GET("/transactions/:id/history", h.GetTransactionHistory)
+	r.POST("/transactions/:id/fail", h.FailTransaction)
+	return r
+}
+
+func TestTransactionHistory_ReturnsEvents(t *testing.T) {
+	s := &stubTransactionService{history: []entity.TransactionEvent{
+		{TransactionID: "trx-1", ToStatus: entity.TransactionStatusPending},
+		{TransactionID: "trx-1", FromStatus: entity.TransactionStatusPending, ToStatus: entity.TransactionStatusFailed},
+	}}
+
+	req, _ := http.NewRequest("GET", "/transactions/trx-1/history", nil)
+	w := httptest.NewRecorder()
+	newRouter(s).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"fromStatus":"pending","toStatus":"failed"`)
+}
+
+func TestTransactionHistory_NotFoundForUnknownTransaction(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/transactions/unknown/history", nil)
+	w := httptest.NewRecorder()
+	newRouter(&stubTransactionService{}).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestTransactionTransition_PassesActorAndReason(t *testing.T) {
+	s := &stubTransactionService{}
+	router := newRouter(s)
+
+	// The body is optional
+	req, _ := http.NewRequest("POST", "/transactions/trx-1/fail", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, entity.TransactionStatusChange{}, s.change)
+
+	req, _ = http.NewRequest("POST", "/transactions/trx-1/fail", strings.NewReader(`{"actor":"support","reason":"Rejected by the issuing bank"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, entity.TransactionStatusChange{Actor: "support", Reason: "Rejected by the issuing bank"}, s.change)
+}
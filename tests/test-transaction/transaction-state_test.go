@@ -0,0 +1,54 @@
+package test_transaction
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yoanesber/go-idempotency-with-redis/internal/entity"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/service"
+)
+
+func TestTransactionState_AllowsLegalTransitionsOnly(t *testing.T) {
+	statuses := []string{
+		entity.TransactionStatusPending,
+		entity.TransactionStatusProcessing,
+		entity.TransactionStatusCompleted,
+		entity.TransactionStatusFailed,
+	}
+
+	legal := map[[2]string]bool{
+		{entity.TransactionStatusPending, entity.TransactionStatusProcessing}:   true,
+		{entity.TransactionStatusPending, entity.TransactionStatusFailed}:       true,
+		{entity.TransactionStatusProcessing, entity.TransactionStatusCompleted}: true,
+		{entity.TransactionStatusProcessing, entity.TransactionStatusFailed}:    true,
+	}
+
+	// Every other transition is rejected, including moving out of a final status and staying in the same status
+	for _, from := range statuses {
+		for _, to := range statuses {
+			assert.Equal(t, legal[[2]string{from, to}], service.CanTransitionTransaction(from, to), "%s -> %s", from, to)
+		}
+	}
+}
+
+func TestTransactionState_DetectsStaleReports(t *testing.T) {
+	tests := []struct {
+		current  string
+		reported string
+		stale    bool
+	}{
+		{entity.TransactionStatusPending, entity.TransactionStatusProcessing, false},
+		{entity.TransactionStatusPending, entity.TransactionStatusCompleted, false},
+		{entity.TransactionStatusProcessing, entity.TransactionStatusFailed, false},
+		{entity.TransactionStatusProcessing, entity.TransactionStatusProcessing, false},
+		{entity.TransactionStatusProcessing, entity.TransactionStatusPending, true},
+		{entity.TransactionStatusCompleted, entity.TransactionStatusProcessing, true},
+		{entity.TransactionStatusCompleted, entity.TransactionStatusFailed, true},
+		{entity.TransactionStatusFailed, entity.TransactionStatusCompleted, true},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.stale, service.IsStaleTransactionStatus(tt.current, tt.reported), "%s reported as %s", tt.current, tt.reported)
+	}
+}
@@ -0,0 +1,171 @@
+package test_webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"github.com/yoanesber/go-idempotency-with-redis/config/database"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/entity"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/handler"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/repository"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/service"
+	"github.com/yoanesber/go-idempotency-with-redis/internal/store"
+	"github.com/yoanesber/go-idempotency-with-redis/pkg/middleware/idempotency"
+	"github.com/yoanesber/go-idempotency-with-redis/pkg/middleware/webhook"
+	httputil "github.com/yoanesber/go-idempotency-with-redis/pkg/util/http-util"
+)
+
+const testTransactionID = "147735b9-eff7-469d-ac85-3b8108825ce4"
+
+// memoryTransactionRepository keeps transactions in memory, so the transaction service runs without a database.
+type memoryTransactionRepository struct {
+	repository.TransactionRepository
+	transactions map[string]entity.Transaction
+}
+
+func (r *memoryTransactionRepository) GetTransactionByID(tx *gorm.DB, id string) (entity.Transaction, error) {
+	t, ok := r.transactions[id]
+	if !ok {
+		return entity.Transaction{}, gorm.ErrRecordNotFound
+	}
+	return t, nil
+}
+
+func (r *memoryTransactionRepository) UpdateTransactionStatus(tx *gorm.DB, t entity.Transaction, status string) (int64, error) {
+	current := r.transactions[t.ID]
+	if current.Status != t.Status || current.Version != t.Version {
+		return 0, nil
+	}
+
+	current.Status = status
+	current.Version++
+	r.transactions[t.ID] = current
+	return 1, nil
+}
+
+// memoryTransactionEventRepository records the status changes in memory.
+type memoryTransactionEventRepository struct {
+	events []entity.TransactionEvent
+}
+
+func (r *memoryTransactionEventRepository) GetTransactionEvents(tx *gorm.DB, transactionID string) ([]entity.TransactionEvent, error) {
+	return r.events, nil
+}
+
+func (r *memoryTransactionEventRepository) CreateTransactionEvent(tx *gorm.DB, e entity.TransactionEvent) (entity.TransactionEvent, error) {
+	r.events = append(r.events, e)
+	return e, nil
+}
+
+// useMockedPostgres replaces the database connection with a mocked one, whose transactions are expected by the test.
+func useMockedPostgres(t *testing.T) sqlmock.Sqlmock {
+	conn, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: conn}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	database.SetPostgres(db)
+	t.Cleanup(func() {
+		database.SetPostgres(nil)
+		conn.Close()
+	})
+
+	return mock
+}
+
+// setupWebhookRouter creates the webhook route with the real handler and transaction service, for a transaction with the given status.
+func setupWebhookRouter(t *testing.T, status string) (*gin.Engine, *memoryTransactionRepository, *memoryTransactionEventRepository) {
+	t.Setenv("WEBHOOK_SECRET", testSecret)
+	t.Setenv("WEBHOOK_TOLERANCE_SECONDS", "300")
+	t.Setenv("IDEMPOTENCY_ENABLED", "TRUE")
+	t.Setenv("IDEMPOTENCY_KEY_HEADER", "Idempotency-Key")
+	t.Setenv("IDEMPOTENCY_PREFIX", "idempotency_cache:")
+	t.Setenv("IDEMPOTENCY_TTL_HOURS", "24")
+
+	repo := &memoryTransactionRepository{transactions: map[string]entity.Transaction{
+		testTransactionID: {ID: testTransactionID, Status: status, Version: 1},
+	}}
+	eventRepo := &memoryTransactionEventRepository{}
+	h := handler.NewWebhookHandler(service.NewTransactionService(repo, eventRepo, nil, nil))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST(testRoute, webhook.VerifySignature(), idempotency.Enforce(store.NewMemoryStore(),
+		idempotency.WithKey(idempotency.KeyFromBodyField("eventId")),
+		idempotency.WithScope(idempotency.FixedScope("webhook:transactions"))), h.HandleTransactionWebhook)
+
+	return router, repo, eventRepo
+}
+
+// webhookBody returns the body of a webhook reporting the given status for the test transaction.
+func webhookBody(eventID string, status string) string {
+	body, _ := json.Marshal(entity.TransactionWebhook{EventID: eventID, TransactionID: testTransactionID, Status: status})
+	return string(body)
+}
+
+// responseStatus returns the status of the transaction in the response of the webhook.
+func responseStatus(t *testing.T, body []byte) string {
+	var resp struct {
+		httputil.HttpResponse
+		Data entity.Transaction `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &resp))
+	return resp.Data.Status
+}
+
+func TestWebhookHandler_AppliesReportedStatus(t *testing.T) {
+	mock := useMockedPostgres(t)
+	router, repo, eventRepo := setupWebhookRouter(t, entity.TransactionStatusPending)
+
+	// A pending transaction reported as completed goes through processing, each step in its own database transaction
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	w := sendWebhook(t, router, testSecret, time.Now(), webhookBody("evt_1", "completed"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, entity.TransactionStatusCompleted, responseStatus(t, w.Body.Bytes()))
+	assert.Equal(t, entity.TransactionStatusCompleted, repo.transactions[testTransactionID].Status)
+	assert.Len(t, eventRepo.events, 2)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhookHandler_AcknowledgesStaleReports(t *testing.T) {
+	mock := useMockedPostgres(t)
+	router, repo, eventRepo := setupWebhookRouter(t, entity.TransactionStatusCompleted)
+
+	// Reports delivered after the transaction completed, out of order or contradicting it, change nothing
+	reports := []struct {
+		eventID string
+		status  string
+	}{
+		{"evt_processing", entity.TransactionStatusProcessing},
+		{"evt_failed", entity.TransactionStatusFailed},
+	}
+
+	for _, r := range reports {
+		w := sendWebhook(t, router, testSecret, time.Now(), webhookBody(r.eventID, r.status))
+		assert.Equal(t, http.StatusOK, w.Code, r.eventID)
+		assert.Equal(t, entity.TransactionStatusCompleted, responseStatus(t, w.Body.Bytes()), r.eventID)
+
+		// The acknowledgement is stored, so a redelivery is replayed instead of being applied again
+		w = sendWebhook(t, router, testSecret, time.Now().Add(time.Second), webhookBody(r.eventID, r.status))
+		assert.Equal(t, http.StatusOK, w.Code, r.eventID)
+		assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"), r.eventID)
+	}
+
+	assert.Equal(t, entity.TransactionStatusCompleted, repo.transactions[testTransactionID].Status)
+	assert.Equal(t, 1, repo.transactions[testTransactionID].Version)
+	assert.Empty(t, eventRepo.events)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,125 @@
+package test_webhook
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yoanesber/go-idempotency-with-redis/internal/store"
+	"github.com/yoanesber/go-idempotency-with-redis/pkg/middleware/idempotency"
+	"github.com/yoanesber/go-idempotency-with-redis/pkg/middleware/webhook"
+)
+
+const (
+	testSecret = "whsec_test"
+	testRoute  = "/api/v1/webhooks/transactions"
+	testBody   = `{"eventId":"evt_1NvXb2","transactionId":"147735b9-eff7-469d-ac85-3b8108825ce4","status":"completed"}`
+)
+
+// setupRouter creates a router with the signature check and the event ID deduplication of the webhook route.
+// The handler counts how many times it is called.
+func setupRouter(t *testing.T, calls *int) *gin.Engine {
+	t.Setenv("WEBHOOK_SECRET", testSecret)
+	t.Setenv("WEBHOOK_TOLERANCE_SECONDS", "300")
+	t.Setenv("IDEMPOTENCY_ENABLED", "TRUE")
+	t.Setenv("IDEMPOTENCY_KEY_HEADER", "Idempotency-Key")
+	t.Setenv("IDEMPOTENCY_PREFIX", "idempotency_cache:")
+	t.Setenv("IDEMPOTENCY_TTL_HOURS", "24")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST(testRoute, webhook.VerifySignature(), idempotency.Enforce(store.NewMemoryStore(),
+		idempotency.WithKey(idempotency.KeyFromBodyField("eventId")),
+		idempotency.WithScope(idempotency.FixedScope("webhook:transactions"))), func(c *gin.Context) {
+		*calls++
+		c.Data(http.StatusOK, "application/json", []byte(`{"status":"completed"}`))
+	})
+
+	return router
+}
+
+// sendWebhook sends a webhook signed with the given secret at the given time and records the response.
+func sendWebhook(t *testing.T, router *gin.Engine, secret string, sentAt time.Time, body string) *httptest.ResponseRecorder {
+	timestamp := strconv.FormatInt(sentAt.Unix(), 10)
+	signature, err := webhook.Sign(secret, timestamp, []byte(body))
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", testRoute, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhook.TimestampHeader, timestamp)
+	req.Header.Set(webhook.SignatureHeader, signature)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestWebhook_AppliesSignedWebhookOnce(t *testing.T) {
+	calls := 0
+	router := setupRouter(t, &calls)
+
+	first := sendWebhook(t, router, testSecret, time.Now(), testBody)
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, 1, calls)
+
+	// The processor delivers the same event again, with a new timestamp and signature
+	second := sendWebhook(t, router, testSecret, time.Now().Add(time.Second), testBody)
+	assert.Equal(t, http.StatusOK, second.Code)
+	assert.Equal(t, "true", second.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, 1, calls)
+}
+
+func TestWebhook_RejectsInvalidSignature(t *testing.T) {
+	calls := 0
+	router := setupRouter(t, &calls)
+
+	w := sendWebhook(t, router, "wrong-secret", time.Now(), testBody)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// A tampered body does not match the signature
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	signature, _ := webhook.Sign(testSecret, timestamp, []byte(testBody))
+	req, _ := http.NewRequest("POST", testRoute, bytes.NewBufferString(`{"eventId":"evt_1NvXb2","status":"failed"}`))
+	req.Header.Set(webhook.TimestampHeader, timestamp)
+	req.Header.Set(webhook.SignatureHeader, signature)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	assert.Equal(t, 0, calls)
+}
+
+func TestWebhook_RejectsTimestampOutsideTolerance(t *testing.T) {
+	calls := 0
+	router := setupRouter(t, &calls)
+
+	assert.Equal(t, http.StatusUnauthorized, sendWebhook(t, router, testSecret, time.Now().Add(-10*time.Minute), testBody).Code)
+	assert.Equal(t, http.StatusUnauthorized, sendWebhook(t, router, testSecret, time.Now().Add(10*time.Minute), testBody).Code)
+	assert.Equal(t, 0, calls)
+}
+
+func TestWebhook_DisabledWithoutSecret(t *testing.T) {
+	calls := 0
+	router := setupRouter(t, &calls)
+	t.Setenv("WEBHOOK_SECRET", "")
+
+	w := sendWebhook(t, router, testSecret, time.Now(), testBody)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, 0, calls)
+}
+
+func TestWebhook_RequiresEventID(t *testing.T) {
+	calls := 0
+	router := setupRouter(t, &calls)
+
+	w := sendWebhook(t, router, testSecret, time.Now(), `{"transactionId":"147735b9-eff7-469d-ac85-3b8108825ce4","status":"completed"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, 0, calls)
+}